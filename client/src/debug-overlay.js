@@ -244,7 +244,7 @@ export class DebugOverlay {
         if (game.gameState.players) {
             for (const playerId in game.gameState.players) {
                 const player = game.gameState.players[playerId];
-                if (player && player.alive && !playerId.startsWith('enemy_')) {
+                if (player && player.alive && !player.isBot) {
                     let displayName = game.playerRenderer.playerDisplayNames.get(playerId);
                     if (!displayName) {
                         if (playerId === game.playerId) {
@@ -4,7 +4,11 @@ export class GameStatsDisplay {
         this.updatePlayersOnline(stats.playersOnline);
         this.updatePlayersAlive(stats.playersAlive);
         this.updateBotsAlive(stats.botsAlive);
-        this.updateTimer(gameState.zoneRadius);
+        if (gameState.queue) {
+            this.updateQueue(gameState.queue);
+        } else {
+            this.updateTimer(gameState.zoneRadius);
+        }
     }
 
     calculateStats(gameState) {
@@ -15,7 +19,7 @@ export class GameStatsDisplay {
         const aliveBots = [];
 
         for (const [playerId, p] of allPlayers) {
-            if (playerId.startsWith('enemy_')) {
+            if (p.isBot) {
                 bots.push([playerId, p]);
                 if (p.alive) aliveBots.push([playerId, p]);
             } else {
@@ -52,6 +56,13 @@ export class GameStatsDisplay {
         }
     }
 
+    updateQueue(queue) {
+        const timerEl = document.getElementById('timer');
+        if (timerEl) {
+            timerEl.textContent = `Queue #${queue.position} (${queue.queued}/${queue.matchSize}) ~${queue.eta}s`;
+        }
+    }
+
     updateTimer(zoneRadius) {
         const timerEl = document.getElementById('timer');
         if (timerEl) {
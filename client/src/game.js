@@ -5,6 +5,7 @@ import { checkBulletCollisionClient, checkBulletHitOnDestroy, getBuildingHitPoin
 import { getBuildingKey, getTreeKey } from './utils.js';
 import { PlayerStatsDisplay } from './player-stats-display.js';
 import { GameStatsDisplay } from './game-stats-display.js';
+import { LobbyPanel } from './lobby-panel.js';
 import { DebugOverlay } from './debug-overlay.js';
 import { WorldRenderer } from './renderers/world-renderer.js';
 import { ZoneRenderer } from './renderers/zone-renderer.js';
@@ -44,7 +45,9 @@ class GameClient {
             ammoPickups: {},
             weaponPickups: {},
             healthPickups: {},
-            zoneCenter: 0,
+            armorPickups: {},
+            zoneCenterX: 0,
+            zoneCenterY: 0,
             zoneRadius: INITIAL_ZONE_RADIUS,
             gameTime: 0,
             phase: 'lobby'
@@ -59,6 +62,7 @@ class GameClient {
         this.viewportManager = new ViewportManager();
         this.playerStatsDisplay = new PlayerStatsDisplay();
         this.gameStatsDisplay = new GameStatsDisplay();
+        this.lobbyPanel = new LobbyPanel(this);
         this.debugOverlay = new DebugOverlay();
         this.mousePos = { x: 0, y: 0 };
         this.lastInput = { moveX: 0, moveY: 0, angle: 0 };
@@ -82,6 +86,8 @@ class GameClient {
         this.lastMovementInputTime = 0;
         this.intendedMove = { x: 0, y: 0 };
         this.interpolationDelay = INTERPOLATION_DELAY_MS;
+        this.serverTick = 0;
+        this.bulletImpacts = new Map();
         this.wasAlive = true;
         this.frameTimes = [];
         this.lastFPSUpdate = 0;
@@ -174,6 +180,11 @@ class GameClient {
             playBtn.addEventListener('click', () => this.screenManager.startGame());
         }
 
+        const createLobbyBtn = document.getElementById('createLobbyBtn');
+        if (createLobbyBtn) {
+            createLobbyBtn.addEventListener('click', () => this.networkManager.createLobby());
+        }
+
         const newGameBtn = document.getElementById('newGameBtn');
         if (newGameBtn) {
             newGameBtn.addEventListener('click', () => this.screenManager.newGame());
@@ -298,6 +309,12 @@ class GameClient {
         this.networkManager.sendShootWithAngle(angle);
     }
 
+    // Remote entities are drawn interpolationDelay behind the latest diff, so
+    // that is the tick the player is aiming at.
+    getViewTick() {
+        return Math.max(0, this.serverTick - Math.round(this.interpolationDelay * TICK_RATE / 1000));
+    }
+
     cleanup() {
         this.gameLoopManager.stop();
 
@@ -447,6 +464,7 @@ class GameClient {
         }
 
         this.gameStatsDisplay.update(this.gameState);
+        this.lobbyPanel.update(this.gameState);
         this.debugOverlay.update(this);
     }
 
@@ -490,7 +508,8 @@ class GameClient {
         this.camera.applyToContainer(this.worldContainer);
 
         this.zoneRenderer.render(
-            this.gameState.zoneCenter,
+            this.gameState.zoneCenterX,
+            this.gameState.zoneCenterY,
             this.gameState.zoneRadius,
             { x: renderX, y: renderY },
             this.deltaTime
@@ -619,6 +638,12 @@ class GameClient {
     }
 
     checkBulletHitOnDestroy(x, y, bulletId) {
+        const impact = this.bulletImpacts.get(bulletId);
+        if (impact) {
+            this.bulletImpacts.delete(bulletId);
+            this.hitAnimationSystem.createHitAnimation(impact.x, impact.y, impact.target);
+            return;
+        }
         return checkBulletHitOnDestroy(
             x, y, bulletId,
             this.gameState,
@@ -1,3 +1,4 @@
+const CONSUMABLE_KEYS = { '3': 'bandage', '4': 'medkit', '5': 'boost' };
 const MOVEMENT_KEYS = ['w', 'a', 's', 'd', 'arrowup', 'arrowdown', 'arrowleft', 'arrowright', 'space'];
 
 export class KeyboardHandler {
@@ -6,6 +7,22 @@ export class KeyboardHandler {
         this.keys = new Set();
     }
 
+    handleActionKey(key) {
+        const network = this.game.networkManager;
+        if (!network) return;
+
+        if (key === 'r') {
+            network.sendAction('reload');
+        } else if (key === '1' || key === '2') {
+            network.sendAction('switchWeapon', { slot: Number(key) - 1 });
+        } else if (CONSUMABLE_KEYS[key]) {
+            network.sendAction('useItem', { item: CONSUMABLE_KEYS[key] });
+        } else if (key === 'g') {
+            const slot = this.game.gameState.inventory?.active ?? 0;
+            network.sendAction('dropItem', { item: 'weapon', slot });
+        }
+    }
+
     setup() {
         const normalizeKey = (e) => {
             if (e.code) {
@@ -26,6 +43,9 @@ export class KeyboardHandler {
         const handleKeyDown = (e) => {
             const key = normalizeKey(e);
             if (!key) return;
+            if (!this.keys.has(key)) {
+                this.handleActionKey(key);
+            }
             if (!this.keys.has(key)) this.keys.add(key);
             if (MOVEMENT_KEYS.includes(key)) {
                 e.preventDefault();
@@ -0,0 +1,99 @@
+const ZONE_SPEEDS = [0.5, 1, 1.5, 2, 3];
+
+export class LobbyPanel {
+    constructor(game) {
+        this.game = game;
+        this.lobby = null;
+        this.renderedLobby = null;
+        this.renderedHost = null;
+    }
+
+    update(gameState) {
+        const panel = document.getElementById('lobbyPanel');
+        if (!panel) return;
+
+        const lobby = gameState.lobby;
+        const visible = lobby && gameState.phase === 'lobby';
+        panel.classList.toggle('hidden', !visible);
+        if (!visible) return;
+
+        const isHost = lobby.host === this.game.playerId;
+        if (lobby === this.renderedLobby && isHost === this.renderedHost) return;
+        this.renderedLobby = lobby;
+        this.renderedHost = isHost;
+
+        panel.innerHTML = '';
+        panel.appendChild(this.createRow(`Lobby <b>${lobby.code}</b>`));
+        panel.appendChild(this.createRow(`Host: ${lobby.host === this.game.playerId ? 'you' : lobby.host}`));
+
+        for (const playerId of lobby.players) {
+            const row = this.createRow(playerId === this.game.playerId ? `${playerId} (you)` : playerId);
+            if (isHost && playerId !== this.game.playerId) {
+                row.appendChild(this.createButton('Kick', () => this.game.networkManager.kickPlayer(playerId)));
+            }
+            panel.appendChild(row);
+        }
+
+        const rules = lobby.rules;
+        if (!isHost) {
+            panel.appendChild(this.createRow(`Players with bots: ${rules.population} · Zone x${rules.zoneSpeed}`));
+            panel.appendChild(this.createRow(`Weapons: ${rules.weapons.join(', ')}`));
+            panel.appendChild(this.createRow('Waiting for the host to start'));
+            return;
+        }
+
+        const population = this.createRow(`Players with bots: ${rules.population} `);
+        population.appendChild(this.createButton('-', () => this.setRules({ population: rules.population - 1 })));
+        population.appendChild(this.createButton('+', () => this.setRules({ population: rules.population + 1 })));
+        panel.appendChild(population);
+
+        const zone = this.createRow('Zone speed: ');
+        const select = document.createElement('select');
+        for (const speed of ZONE_SPEEDS) {
+            const option = document.createElement('option');
+            option.value = speed;
+            option.textContent = `x${speed}`;
+            option.selected = speed === rules.zoneSpeed;
+            select.appendChild(option);
+        }
+        select.addEventListener('change', () => this.setRules({ zoneSpeed: parseFloat(select.value) }));
+        zone.appendChild(select);
+        panel.appendChild(zone);
+
+        for (const weapon of lobby.availableWeapons) {
+            const row = this.createRow('');
+            const checkbox = document.createElement('input');
+            checkbox.type = 'checkbox';
+            checkbox.checked = rules.weapons.includes(weapon);
+            checkbox.addEventListener('change', () => {
+                const weapons = lobby.availableWeapons.filter(w => w === weapon ? checkbox.checked : rules.weapons.includes(w));
+                this.setRules({ weapons });
+            });
+            const label = document.createElement('label');
+            label.appendChild(checkbox);
+            label.appendChild(document.createTextNode(` ${weapon}`));
+            row.appendChild(label);
+            panel.appendChild(row);
+        }
+
+        panel.appendChild(this.createButton('Start match', () => this.game.networkManager.startLobbyMatch()));
+    }
+
+    setRules(changes) {
+        this.game.networkManager.setLobbyRules({ ...this.renderedLobby.rules, ...changes });
+    }
+
+    createRow(html) {
+        const row = document.createElement('div');
+        row.className = 'lobby-row';
+        row.innerHTML = html;
+        return row;
+    }
+
+    createButton(text, onClick) {
+        const button = document.createElement('button');
+        button.textContent = text;
+        button.addEventListener('click', onClick);
+        return button;
+    }
+}
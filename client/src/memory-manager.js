@@ -60,7 +60,8 @@ export class MemoryManager {
             for (const [pickupId, graphics] of this.game.pickupRenderer.pickupGraphicsCache) {
                 const isActive = activeIds.ammo.has(pickupId) ||
                     activeIds.weapon.has(pickupId) ||
-                    activeIds.health.has(pickupId);
+                    activeIds.health.has(pickupId) ||
+                    activeIds.armor.has(pickupId);
                 if (!isActive) {
                     inactivePickups.push(pickupId);
                 }
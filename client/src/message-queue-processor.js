@@ -33,19 +33,29 @@ export class MessageQueueProcessor {
 
         for (const data of messages) {
             if (data.type === 'init' && data.playerId) {
-                console.log('Received init, playerId:', data.playerId);
+                console.log('Received init, playerId:', data.playerId, 'room:', data.roomId, 'capabilities:', data.capabilities);
                 if (data.sessionId) {
                     this.game.sessionManager.setSessionId(data.sessionId);
                     console.log('Session ID:', this.game.sessionId);
                 }
                 this.game.playerId = data.playerId;
                 this.game.hitAnimationSystem.playerId = data.playerId;
+                this.game.gameState.queue = null;
                 if (data.state) {
                     this.game.applyStateDiff(data.state);
                 }
                 if (this.game.gameState.buildings?.length) {
                     this.game.scheduleGenerateWorld();
                 }
+            } else if (data.type === 'queue') {
+                this.game.gameState.queue = data;
+            } else if (data.type === 'lobby') {
+                console.log('Lobby', data.code, 'host:', data.host, 'players:', data.players, 'rules:', data.rules);
+                this.game.gameState.lobby = data;
+            } else if (data.type === 'phase') {
+                this.game.gameState.phaseEndTick = data.endTick || 0;
+                this.game.gameState.phaseCountdown = data.countdown || 0;
+                this.game.gameState.matchId = data.matchId;
             } else if (data.type === 'worldChunks') {
                 this.game.handleWorldChunks(data.chunks);
             } else if (data.type === 'stateDiff') {
@@ -69,7 +79,6 @@ export class MessageQueueProcessor {
                         this.game.correctionErrorSamples = 0;
                         this.game.pendingInputs = [];
                         this.game.lastAcknowledgedInputId = -1;
-                        this.game.inputSequence = 0;
                     }
                 }
             }
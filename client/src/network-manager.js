@@ -1,4 +1,25 @@
 import { roundCoord, roundAngle } from './utils.js';
+import {
+    BinaryDecoder,
+    encodeInput,
+    ENCODING_BINARY,
+    ENCODING_JSON,
+    PROTOCOL_VERSION,
+    CLIENT_FEATURES,
+    CLOSE_HANDSHAKE_FAILED,
+    CLOSE_UNSUPPORTED_VERSION,
+    CLOSE_UNKNOWN_LOBBY,
+    CLOSE_LOBBY_CLOSED,
+    CLOSE_KICKED
+} from './protocol.js';
+
+const REJECT_CLOSE_CODES = [
+    CLOSE_HANDSHAKE_FAILED,
+    CLOSE_UNSUPPORTED_VERSION,
+    CLOSE_UNKNOWN_LOBBY,
+    CLOSE_LOBBY_CLOSED,
+    CLOSE_KICKED
+];
 
 export class NetworkManager {
     constructor(game) {
@@ -7,6 +28,8 @@ export class NetworkManager {
         this.isConnecting = false;
         this.wsPacketsSent = 0;
         this.wsPacketsReceived = 0;
+        this.encoding = new URLSearchParams(window.location.search).get('encoding') === ENCODING_BINARY ? ENCODING_BINARY : ENCODING_JSON;
+        this.decoder = null;
     }
 
     connect() {
@@ -16,18 +39,40 @@ export class NetworkManager {
 
         this.isConnecting = true;
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const sessionParam = this.game.sessionId ? `?session=${encodeURIComponent(this.game.sessionId)}` : '';
-        const wsUrl = `${protocol}//${window.location.host}/ws${sessionParam}`;
+        const params = new URLSearchParams();
+        if (this.game.sessionId) {
+            params.set('session', this.game.sessionId);
+        }
+        const pageParams = new URLSearchParams(window.location.search);
+        const lobby = pageParams.get('lobby');
+        const room = pageParams.get('room');
+        if (lobby) {
+            params.set('lobby', lobby);
+        } else if (room) {
+            params.set('room', room);
+        }
+        const query = params.toString();
+        const wsUrl = `${protocol}//${window.location.host}/ws${query ? `?${query}` : ''}`;
 
         if (this.ws) {
             this.ws.close();
         }
 
         this.ws = new WebSocket(wsUrl);
+        this.ws.binaryType = 'arraybuffer';
+        this.decoder = new BinaryDecoder();
 
         this.ws.onopen = () => {
             console.log('Connected to server');
             this.isConnecting = false;
+            // JSON compresses well; binary frames are already small.
+            this.ws.send(JSON.stringify({
+                type: 'hello',
+                protocolVersion: PROTOCOL_VERSION,
+                encoding: this.encoding,
+                compression: this.encoding === ENCODING_JSON,
+                features: CLIENT_FEATURES
+            }));
         };
 
         this.ws.onmessage = (event) => {
@@ -36,7 +81,7 @@ export class NetworkManager {
                 return;
             }
             try {
-                const data = JSON.parse(event.data);
+                const data = event.data instanceof ArrayBuffer ? this.decoder.decode(event.data) : JSON.parse(event.data);
                 if (data.type === 'pong' && data.time) {
                     const pingEnd = performance.now();
                     this.game.ping = Math.round(pingEnd - data.time);
@@ -59,6 +104,10 @@ export class NetworkManager {
         this.ws.onclose = (event) => {
             console.log('Disconnected from server', event.code);
             this.isConnecting = false;
+            if (REJECT_CLOSE_CODES.includes(event.code)) {
+                console.error('Server rejected connection, not reconnecting:', event.reason);
+                return;
+            }
             if (event.code !== 1000 && event.code !== 1001 && !this.isConnecting && !document.hidden) {
                 setTimeout(() => {
                     if (!document.hidden && !this.isConnecting && (!this.ws || this.ws.readyState !== WebSocket.OPEN)) {
@@ -87,7 +136,7 @@ export class NetworkManager {
     }
 
     sendInput(moveX, moveY, angle) {
-        if (!this.ws || this.ws.readyState !== WebSocket.OPEN) {
+        if (!this.ws || this.ws.readyState !== WebSocket.OPEN || this.game.gameState.queue) {
             return false;
         }
 
@@ -131,7 +180,7 @@ export class NetworkManager {
             };
 
             this.wsPacketsSent++;
-            this.ws.send(JSON.stringify(message));
+            this.ws.send(this.encodeInput(message));
             return true;
         } catch (error) {
             console.error('Error sending input:', error);
@@ -142,12 +191,16 @@ export class NetworkManager {
         }
     }
 
+    encodeInput(message) {
+        return this.encoding === ENCODING_BINARY ? encodeInput(message) : JSON.stringify(message);
+    }
+
     sendShoot() {
         if (!this.ws || this.ws.readyState !== WebSocket.OPEN) {
             return;
         }
         const player = this.game.gameState.players[this.game.playerId];
-        if (!player || player.ammo <= 0) {
+        if (!player || (player.magazine || 0) + (player.ammo || 0) <= 0) {
             return;
         }
         let angle;
@@ -162,11 +215,11 @@ export class NetworkManager {
     }
 
     sendShootWithAngle(angle) {
-        if (!this.ws || this.ws.readyState !== WebSocket.OPEN || this.ws.bufferedAmount > 65536) {
+        if (!this.ws || this.ws.readyState !== WebSocket.OPEN || this.ws.bufferedAmount > 65536 || this.game.gameState.queue) {
             return;
         }
         const player = this.game.gameState.players[this.game.playerId];
-        if (!player || player.ammo <= 0) {
+        if (!player || (player.magazine || 0) + (player.ammo || 0) <= 0) {
             return;
         }
         const now = Date.now();
@@ -190,12 +243,13 @@ export class NetworkManager {
 
         try {
             this.wsPacketsSent++;
-            this.ws.send(JSON.stringify({
+            this.ws.send(this.encodeInput({
                 type: 'input',
                 moveX: 0,
                 moveY: 0,
                 angle: roundAngle(angle),
-                shoot: true
+                shoot: true,
+                tick: this.game.getViewTick()
             }));
         } catch (error) {
             console.error('Error sending shoot:', error);
@@ -205,6 +259,42 @@ export class NetworkManager {
         }
     }
 
+    async createLobby() {
+        try {
+            const response = await fetch('/lobbies', { method: 'POST' });
+            if (!response.ok) {
+                throw new Error(`HTTP ${response.status}`);
+            }
+            const { code } = await response.json();
+            window.location.search = `?lobby=${code}`;
+        } catch (error) {
+            console.error('Error creating lobby:', error);
+        }
+    }
+
+    setLobbyRules(rules) {
+        this.sendAction('lobbyRules', { rules });
+    }
+
+    startLobbyMatch() {
+        this.sendAction('startMatch');
+    }
+
+    kickPlayer(playerId) {
+        this.sendAction('kick', { playerId });
+    }
+
+    sendAction(type, fields = {}) {
+        if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+            try {
+                this.wsPacketsSent++;
+                this.ws.send(JSON.stringify({ type, ...fields }));
+            } catch (error) {
+                console.error(`Error sending ${type}:`, error);
+            }
+        }
+    }
+
     sendPing() {
         if (this.ws && this.ws.readyState === WebSocket.OPEN) {
             try {
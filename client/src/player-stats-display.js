@@ -9,20 +9,27 @@ export class PlayerStatsDisplay {
     update(player) {
         if (!player) return;
 
-        this.updateHealth(player.health);
-        this.updateAmmo(player.ammo);
+        this.updateHealth(player.health, player.healing, player.healProgress, player.boost);
+        this.updateAmmo(player.ammo, player.magazine, player.reloading, player.reloadProgress);
         this.updateWeapon(player.weapon);
         this.updateKills(player.kills);
         this.updatePosition(player.x, player.y);
         this.updateAngle(player.angle);
     }
 
-    updateHealth(health) {
+    updateHealth(health, healing, healProgress, boost) {
         const healthEl = document.getElementById('health');
         if (!healthEl) return;
 
         const healthChanged = this.lastHealth !== null && this.lastHealth < health;
-        healthEl.textContent = health;
+        let text = `${health}`;
+        if (healing) {
+            text += ` (${healing} ${Math.round((healProgress || 0) * 100)}%)`;
+        }
+        if (boost) {
+            text += ` +${Math.round(boost)} boost`;
+        }
+        healthEl.textContent = text;
 
         if (healthChanged) {
             healthEl.style.color = '#00FF00';
@@ -36,13 +43,17 @@ export class PlayerStatsDisplay {
         this.lastHealth = health;
     }
 
-    updateAmmo(ammo) {
+    updateAmmo(ammo, magazine, reloading, reloadProgress) {
         const ammoEl = document.getElementById('ammo');
         if (!ammoEl) return;
 
         const ammoValue = ammo || 0;
         const ammoChanged = this.lastAmmo !== null && this.lastAmmo < ammoValue;
-        ammoEl.textContent = ammoValue;
+        if (reloading) {
+            ammoEl.textContent = `Reloading ${Math.round((reloadProgress || 0) * 100)}%`;
+        } else {
+            ammoEl.textContent = `${magazine || 0} / ${ammoValue}`;
+        }
 
         if (ammoChanged) {
             ammoEl.style.color = '#00FF00';
@@ -0,0 +1,447 @@
+// Binary wire format, mirrored from server/protocol.go. Field order and bit
+// positions must match the server exactly.
+
+export const PROTOCOL_VERSION = 3;
+export const CLIENT_FEATURES = ['inventory', 'impacts'];
+
+// Close codes the server uses when it rejects the hello or a lobby join.
+export const CLOSE_HANDSHAKE_FAILED = 4000;
+export const CLOSE_UNSUPPORTED_VERSION = 4001;
+export const CLOSE_UNKNOWN_LOBBY = 4002;
+export const CLOSE_LOBBY_CLOSED = 4003;
+export const CLOSE_KICKED = 4004;
+
+export const ENCODING_JSON = 'json';
+export const ENCODING_BINARY = 'binary';
+
+const MSG_STATE_DIFF = 1;
+const MSG_WORLD_CHUNKS = 2;
+const MSG_INPUT = 3;
+
+const ID_PREFIXES = ['', 'player_', 'enemy_', 'bullet_', 'ammo_', 'weapon_', 'health_', 'armor_'];
+
+const PLAYER_FIELDS = [
+    ['x', 'coord'],
+    ['y', 'coord'],
+    ['angle', 'angle'],
+    ['health', 'varint'],
+    ['alive', 'bool'],
+    ['velocity', 'coord'],
+    ['ammo', 'varint'],
+    ['magazine', 'varint'],
+    ['weapon', 'string'],
+    ['score', 'varint'],
+    ['kills', 'varint'],
+    ['reloading', 'bool'],
+    ['reloadProgress', 'fraction'],
+    ['vestTier', 'varint'],
+    ['vestDurability', 'varint'],
+    ['helmetTier', 'varint'],
+    ['helmetDurability', 'varint'],
+    ['healing', 'string'],
+    ['healProgress', 'fraction'],
+    ['boost', 'coord'],
+    ['isBot', 'bool']
+];
+
+const SCALAR_FIELDS = [
+    ['gameTime', 'varint'],
+    ['phase', 'string'],
+    ['phaseEndTick', 'varint'],
+    ['matchId', 'varint'],
+    ['winner', 'id'],
+    ['lastProcessedInput', 'varint']
+];
+
+const SECTION_PLAYERS = 0;
+const SECTION_BULLETS = 1;
+const SECTION_AMMO = 2;
+const SECTION_WEAPONS = 3;
+const SECTION_HEALTH = 4;
+const SECTION_ARMOR = 5;
+const SECTION_REMOVED = ['removedPlayers', 'removedBullets', 'removedAmmo', 'removedWeapons', 'removedHealth', 'removedArmor'];
+const SECTION_REMOVED_FIRST = 6;
+const SECTION_INVENTORY = 12;
+const SECTION_IMPACTS = 13;
+const SECTION_ZONE = 14;
+const SECTION_SCALARS = 15;
+
+const INPUT_MOVE_X = 0;
+const INPUT_MOVE_Y = 1;
+const INPUT_ANGLE = 2;
+const INPUT_SHOOT = 3;
+const INPUT_CLIENT_X = 4;
+const INPUT_CLIENT_Y = 5;
+const INPUT_ID = 6;
+const INPUT_TICK = 7;
+
+const textDecoder = new TextDecoder();
+
+// Masks can exceed 32 bits, so bits are tested with arithmetic instead of
+// bitwise operators.
+function hasBit(mask, bit) {
+    return Math.floor(mask / 2 ** bit) % 2 === 1;
+}
+
+class BinaryReader {
+    constructor(buffer) {
+        this.bytes = new Uint8Array(buffer);
+        this.pos = 0;
+    }
+
+    byte() {
+        if (this.pos >= this.bytes.length) {
+            throw new Error('binary message truncated');
+        }
+        return this.bytes[this.pos++];
+    }
+
+    uvarint() {
+        let result = 0;
+        let multiplier = 1;
+        for (;;) {
+            const b = this.byte();
+            result += (b & 0x7f) * multiplier;
+            if (b < 0x80) return result;
+            multiplier *= 128;
+        }
+    }
+
+    varint() {
+        const n = this.uvarint();
+        return n % 2 === 0 ? n / 2 : -(n + 1) / 2;
+    }
+
+    coord() {
+        return this.varint() / 100;
+    }
+
+    fraction() {
+        return this.uvarint() / 100;
+    }
+
+    angle() {
+        const q = this.byte() | (this.byte() << 8);
+        return q / 65536 * 2 * Math.PI - Math.PI;
+    }
+
+    bool() {
+        return this.byte() !== 0;
+    }
+
+    string() {
+        const length = this.uvarint();
+        if (this.pos + length > this.bytes.length) {
+            throw new Error('binary message truncated');
+        }
+        const value = textDecoder.decode(this.bytes.subarray(this.pos, this.pos + length));
+        this.pos += length;
+        return value;
+    }
+
+    id() {
+        const code = this.byte();
+        if (code === 0) {
+            return this.string();
+        }
+        return ID_PREFIXES[code] + this.uvarint();
+    }
+
+    ids() {
+        const count = this.uvarint();
+        const result = [];
+        for (let i = 0; i < count; i++) {
+            result.push(this.id());
+        }
+        return result;
+    }
+
+    field(kind) {
+        return this[kind]();
+    }
+}
+
+class BinaryWriter {
+    constructor() {
+        this.bytes = [];
+    }
+
+    byte(b) {
+        this.bytes.push(b);
+    }
+
+    uvarint(v) {
+        while (v >= 0x80) {
+            this.bytes.push((v % 128) | 0x80);
+            v = Math.floor(v / 128);
+        }
+        this.bytes.push(v);
+    }
+
+    varint(v) {
+        this.uvarint(v >= 0 ? v * 2 : -v * 2 - 1);
+    }
+
+    coord(v) {
+        this.varint(Math.round(v * 100));
+    }
+
+    angle(v) {
+        let turn = (v + Math.PI) % (2 * Math.PI);
+        if (turn < 0) turn += 2 * Math.PI;
+        const q = Math.round(turn / (2 * Math.PI) * 65536) & 0xffff;
+        this.bytes.push(q & 0xff, q >> 8);
+    }
+
+    toBuffer() {
+        return new Uint8Array(this.bytes).buffer;
+    }
+}
+
+// BinaryDecoder turns binary frames into the same objects JSON frames parse
+// to. It keeps the last decoded copy of every player because the server only
+// sends the fields that changed.
+export class BinaryDecoder {
+    constructor() {
+        this.players = new Map();
+    }
+
+    decode(buffer) {
+        const reader = new BinaryReader(buffer);
+        const type = reader.byte();
+        if (type === MSG_STATE_DIFF) {
+            return this.decodeStateDiff(reader);
+        }
+        if (type === MSG_WORLD_CHUNKS) {
+            return this.decodeWorldChunks(reader);
+        }
+        throw new Error(`unknown binary message type ${type}`);
+    }
+
+    decodeStateDiff(reader) {
+        const diff = { type: 'stateDiff', tick: reader.uvarint() };
+        const sections = reader.uvarint();
+
+        if (hasBit(sections, SECTION_PLAYERS)) {
+            diff.players = {};
+            const count = reader.uvarint();
+            for (let i = 0; i < count; i++) {
+                const id = reader.id();
+                const mask = reader.uvarint();
+                const player = { ...(this.players.get(id) || {}), id };
+                PLAYER_FIELDS.forEach(([name, kind], bit) => {
+                    if (hasBit(mask, bit)) {
+                        player[name] = reader.field(kind);
+                    }
+                });
+                this.players.set(id, player);
+                diff.players[id] = { ...player };
+            }
+        }
+
+        if (hasBit(sections, SECTION_BULLETS)) {
+            diff.bullets = {};
+            const count = reader.uvarint();
+            for (let i = 0; i < count; i++) {
+                const id = reader.id();
+                diff.bullets[id] = {
+                    id,
+                    playerId: reader.id(),
+                    x: reader.coord(),
+                    y: reader.coord(),
+                    angle: reader.angle(),
+                    speed: reader.coord(),
+                    active: reader.bool(),
+                    weapon: reader.string()
+                };
+            }
+        }
+
+        if (hasBit(sections, SECTION_AMMO)) {
+            diff.ammoPickups = {};
+            const count = reader.uvarint();
+            for (let i = 0; i < count; i++) {
+                const id = reader.id();
+                diff.ammoPickups[id] = {
+                    id,
+                    x: reader.coord(),
+                    y: reader.coord(),
+                    amount: reader.varint(),
+                    active: reader.bool()
+                };
+            }
+        }
+
+        if (hasBit(sections, SECTION_WEAPONS)) {
+            diff.weaponPickups = {};
+            const count = reader.uvarint();
+            for (let i = 0; i < count; i++) {
+                const id = reader.id();
+                diff.weaponPickups[id] = {
+                    id,
+                    x: reader.coord(),
+                    y: reader.coord(),
+                    weapon: reader.string(),
+                    active: reader.bool()
+                };
+            }
+        }
+
+        if (hasBit(sections, SECTION_HEALTH)) {
+            diff.healthPickups = {};
+            const count = reader.uvarint();
+            for (let i = 0; i < count; i++) {
+                const id = reader.id();
+                diff.healthPickups[id] = {
+                    id,
+                    x: reader.coord(),
+                    y: reader.coord(),
+                    item: reader.string(),
+                    amount: reader.varint(),
+                    active: reader.bool()
+                };
+            }
+        }
+
+        if (hasBit(sections, SECTION_ARMOR)) {
+            diff.armorPickups = {};
+            const count = reader.uvarint();
+            for (let i = 0; i < count; i++) {
+                const id = reader.id();
+                diff.armorPickups[id] = {
+                    id,
+                    x: reader.coord(),
+                    y: reader.coord(),
+                    kind: reader.string(),
+                    tier: reader.varint(),
+                    durability: reader.varint(),
+                    active: reader.bool()
+                };
+            }
+        }
+
+        SECTION_REMOVED.forEach((name, i) => {
+            if (hasBit(sections, SECTION_REMOVED_FIRST + i)) {
+                diff[name] = reader.ids();
+            }
+        });
+        if (diff.removedPlayers) {
+            for (const id of diff.removedPlayers) {
+                this.players.delete(id);
+            }
+        }
+
+        if (hasBit(sections, SECTION_INVENTORY)) {
+            const slots = [];
+            const slotCount = reader.uvarint();
+            for (let i = 0; i < slotCount; i++) {
+                slots.push({ weapon: reader.string(), magazine: reader.varint() });
+            }
+            const active = reader.uvarint();
+            const consumables = {};
+            const consumableCount = reader.uvarint();
+            for (let i = 0; i < consumableCount; i++) {
+                const name = reader.string();
+                consumables[name] = reader.varint();
+            }
+            diff.inventory = { slots, active, consumables };
+        }
+
+        if (hasBit(sections, SECTION_IMPACTS)) {
+            diff.impacts = [];
+            const count = reader.uvarint();
+            for (let i = 0; i < count; i++) {
+                const impact = {
+                    bulletId: reader.id(),
+                    x: reader.coord(),
+                    y: reader.coord(),
+                    target: reader.string()
+                };
+                const playerId = reader.id();
+                if (playerId) impact.playerId = playerId;
+                diff.impacts.push(impact);
+            }
+        }
+
+        if (hasBit(sections, SECTION_ZONE)) {
+            diff.zone = {
+                centerX: reader.coord(),
+                centerY: reader.coord(),
+                radius: reader.coord(),
+                nextX: reader.coord(),
+                nextY: reader.coord(),
+                nextRadius: reader.coord(),
+                stage: reader.varint(),
+                state: reader.string(),
+                stateEndTick: reader.varint()
+            };
+        }
+
+        if (hasBit(sections, SECTION_SCALARS)) {
+            const scalars = reader.uvarint();
+            SCALAR_FIELDS.forEach(([name, kind], bit) => {
+                if (hasBit(scalars, bit)) {
+                    diff[name] = reader.field(kind);
+                }
+            });
+        }
+
+        return diff;
+    }
+
+    decodeWorldChunks(reader) {
+        const chunks = [];
+        const count = reader.uvarint();
+        for (let i = 0; i < count; i++) {
+            const chunk = { chunkX: reader.varint(), chunkY: reader.varint(), buildings: [], trees: [] };
+            const buildingCount = reader.uvarint();
+            for (let j = 0; j < buildingCount; j++) {
+                chunk.buildings.push({
+                    x: reader.coord(),
+                    y: reader.coord(),
+                    width: reader.coord(),
+                    height: reader.coord()
+                });
+            }
+            const treeCount = reader.uvarint();
+            for (let j = 0; j < treeCount; j++) {
+                chunk.trees.push({
+                    x: reader.coord(),
+                    y: reader.coord(),
+                    size: reader.coord(),
+                    type: reader.string()
+                });
+            }
+            chunks.push(chunk);
+        }
+        return { type: 'worldChunks', chunks };
+    }
+}
+
+// encodeInput packs an input message. Only movement and shooting inputs have
+// a binary form; everything else is sent as JSON.
+export function encodeInput(message) {
+    const writer = new BinaryWriter();
+    writer.byte(MSG_INPUT);
+
+    let mask = 0;
+    if (message.moveX) mask |= 1 << INPUT_MOVE_X;
+    if (message.moveY) mask |= 1 << INPUT_MOVE_Y;
+    if (message.angle) mask |= 1 << INPUT_ANGLE;
+    if (message.shoot) mask |= 1 << INPUT_SHOOT;
+    if (message.clientX) mask |= 1 << INPUT_CLIENT_X;
+    if (message.clientY) mask |= 1 << INPUT_CLIENT_Y;
+    if (message.inputId) mask |= 1 << INPUT_ID;
+    if (message.tick) mask |= 1 << INPUT_TICK;
+    writer.uvarint(mask);
+
+    if (message.moveX) writer.coord(message.moveX);
+    if (message.moveY) writer.coord(message.moveY);
+    if (message.angle) writer.angle(message.angle);
+    if (message.clientX) writer.coord(message.clientX);
+    if (message.clientY) writer.coord(message.clientY);
+    if (message.inputId) writer.uvarint(message.inputId);
+    if (message.tick) writer.uvarint(message.tick);
+
+    return writer.toBuffer();
+}
@@ -2,6 +2,7 @@ import * as PIXI from 'pixi.js';
 import { TRIM_BATCH, destroyAndClearCache } from '../utils.js';
 
 const MAX_PICKUP_CACHE = 300;
+const CONSUMABLE_COLORS = { bandage: 0xFF0000, medkit: 0x00AA00, boost: 0xFFB000 };
 
 export class PickupRenderer {
     constructor(ammoContainer, weaponContainer, healthContainer) {
@@ -12,6 +13,7 @@ export class PickupRenderer {
         this.activeAmmoIds = new Set();
         this.activeWeaponIds = new Set();
         this.activeHealthIds = new Set();
+        this.activeArmorIds = new Set();
     }
 
     render(gameState, viewport) {
@@ -146,19 +148,20 @@ export class PickupRenderer {
 
                 let healthGraphics = this.pickupGraphicsCache.get(healthId);
                 if (!healthGraphics) {
+                    const crossColor = CONSUMABLE_COLORS[health.item] || 0xFF0000;
                     healthGraphics = new PIXI.Graphics();
                     healthGraphics.lineStyle(0);
                     healthGraphics.beginFill(0xFFFFFF);
                     healthGraphics.drawCircle(0, 0, 12);
                     healthGraphics.endFill();
 
-                    healthGraphics.lineStyle(2, 0xFF0000);
+                    healthGraphics.lineStyle(2, crossColor);
                     healthGraphics.beginFill(0xFFFFFF, 0);
                     healthGraphics.drawCircle(0, 0, 12);
                     healthGraphics.endFill();
                     healthGraphics.lineStyle(0);
 
-                    healthGraphics.beginFill(0xFF0000);
+                    healthGraphics.beginFill(crossColor);
                     healthGraphics.drawRect(-6, -2, 12, 4);
                     healthGraphics.drawRect(-2, -6, 4, 12);
                     healthGraphics.endFill();
@@ -173,8 +176,45 @@ export class PickupRenderer {
             }
         }
 
+        this.activeArmorIds.clear();
+        if (gameState.armorPickups) {
+            for (const armorId in gameState.armorPickups) {
+                const armor = gameState.armorPickups[armorId];
+                if (!armor.active) continue;
+
+                if (viewport && (armor.x < viewport.viewLeft || armor.x > viewport.viewRight ||
+                    armor.y < viewport.viewTop || armor.y > viewport.viewBottom)) {
+                    continue;
+                }
+
+                this.activeArmorIds.add(armorId);
+
+                let armorGraphics = this.pickupGraphicsCache.get(armorId);
+                if (!armorGraphics) {
+                    const tierColors = { 1: 0xC0C0C0, 2: 0x4A90E2, 3: 0x9B59B6 };
+                    armorGraphics = new PIXI.Graphics();
+                    armorGraphics.lineStyle(2, 0x222222);
+                    armorGraphics.beginFill(tierColors[armor.tier] || 0xC0C0C0);
+                    if (armor.kind === 'helmet') {
+                        armorGraphics.drawCircle(0, 0, 10);
+                    } else {
+                        armorGraphics.drawRoundedRect(-10, -10, 20, 20, 4);
+                    }
+                    armorGraphics.endFill();
+
+                    this.pickupGraphicsCache.set(armorId, armorGraphics);
+                    this.healthContainer.addChild(armorGraphics);
+                }
+
+                armorGraphics.x = armor.x;
+                armorGraphics.y = armor.y;
+                armorGraphics.visible = true;
+            }
+        }
+
         for (const [healthId, graphics] of this.pickupGraphicsCache) {
-            if (!this.activeHealthIds.has(healthId) && graphics.parent === this.healthContainer) {
+            if (!this.activeHealthIds.has(healthId) && !this.activeArmorIds.has(healthId) &&
+                graphics.parent === this.healthContainer) {
                 graphics.visible = false;
             }
         }
@@ -186,7 +226,8 @@ export class PickupRenderer {
             for (const [pickupId] of this.pickupGraphicsCache) {
                 const isActive = this.activeAmmoIds.has(pickupId) ||
                     this.activeWeaponIds.has(pickupId) ||
-                    this.activeHealthIds.has(pickupId);
+                    this.activeHealthIds.has(pickupId) ||
+                    this.activeArmorIds.has(pickupId);
                 if (!isActive) {
                     inactivePickups.push(pickupId);
                 }
@@ -212,13 +253,15 @@ export class PickupRenderer {
         this.activeAmmoIds.clear();
         this.activeWeaponIds.clear();
         this.activeHealthIds.clear();
+        this.activeArmorIds.clear();
     }
 
     getActiveIds() {
         return {
             ammo: this.activeAmmoIds,
             weapon: this.activeWeaponIds,
-            health: this.activeHealthIds
+            health: this.activeHealthIds,
+            armor: this.activeArmorIds
         };
     }
 }
@@ -55,7 +55,7 @@ export class PlayerRenderer {
 
             let playerContainer = this.playerGraphicsCache.get(playerIdKey);
             if (!playerContainer) {
-                playerContainer = this.createPlayerGraphics(playerIdKey, isMyPlayer);
+                playerContainer = this.createPlayerGraphics(playerIdKey, isMyPlayer, playerData.isBot);
                 this.playerGraphicsCache.set(playerIdKey, playerContainer);
                 this.playersContainer.addChild(playerContainer);
             }
@@ -126,7 +126,7 @@ export class PlayerRenderer {
         return { renderX, renderY, renderAngle };
     }
 
-    createPlayerGraphics(playerIdKey, isMyPlayer) {
+    createPlayerGraphics(playerIdKey, isMyPlayer, isBot) {
         const playerContainer = new PIXI.Container();
         playerContainer.visible = true;
         const bodyColor = isMyPlayer ? 0x00FF00 : 0x4169E1;
@@ -175,8 +175,8 @@ export class PlayerRenderer {
         if (!isMyPlayer) {
             let displayName = this.playerDisplayNames.get(playerIdKey);
             if (!displayName) {
-                if (playerIdKey.startsWith('enemy_')) {
-                    displayName = `Bot${playerIdKey.substring(6)}`;
+                if (isBot) {
+                    displayName = `Bot${playerIdKey.substring(playerIdKey.lastIndexOf('_') + 1)}`;
                 } else {
                     displayName = `P${playerIdKey.slice(-3)}`;
                 }
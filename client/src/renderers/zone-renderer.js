@@ -17,17 +17,17 @@ export class ZoneRenderer {
         this.worldContainer.addChild(this.zoneIndicatorGraphics);
 
         this.interpolatedZoneRadius = 0;
-        this.interpolatedZoneCenter = 0;
+        this.interpolatedZoneCenterX = null;
+        this.interpolatedZoneCenterY = null;
         this.lastRenderedZoneRadius = 0;
-        this.lastRenderedZoneCenter = 0;
-        this.lastZoneRadius = 0;
-        this.lastZoneCenter = 0;
+        this.lastRenderedZoneCenterX = 0;
+        this.lastRenderedZoneCenterY = 0;
         this.lastZoneArrowAngle = undefined;
         this.zoneIndicatorHasArrow = false;
     }
 
-    render(zoneCenter, zoneRadius, playerPos, deltaTime) {
-        if (zoneCenter === undefined || zoneRadius === undefined) {
+    render(zoneCenterX, zoneCenterY, zoneRadius, playerPos, deltaTime) {
+        if (zoneCenterX === undefined || zoneCenterY === undefined || zoneRadius === undefined) {
             return;
         }
 
@@ -36,71 +36,73 @@ export class ZoneRenderer {
         if (this.interpolatedZoneRadius === 0) {
             this.interpolatedZoneRadius = zoneRadius;
         }
-        if (this.interpolatedZoneCenter === 0) {
-            this.interpolatedZoneCenter = zoneCenter;
+        if (this.interpolatedZoneCenterX === null) {
+            this.interpolatedZoneCenterX = zoneCenterX;
+            this.interpolatedZoneCenterY = zoneCenterY;
         }
 
         const smoothingTime = 0.1;
         const smoothingFactor = Math.min(1.0, frameDelta / smoothingTime);
 
         const radiusDiff = zoneRadius - this.interpolatedZoneRadius;
-        const centerDiff = zoneCenter - this.interpolatedZoneCenter;
+        const centerDiffX = zoneCenterX - this.interpolatedZoneCenterX;
+        const centerDiffY = zoneCenterY - this.interpolatedZoneCenterY;
 
         this.interpolatedZoneRadius += radiusDiff * smoothingFactor;
-        this.interpolatedZoneCenter += centerDiff * smoothingFactor;
+        this.interpolatedZoneCenterX += centerDiffX * smoothingFactor;
+        this.interpolatedZoneCenterY += centerDiffY * smoothingFactor;
 
         const renderRadius = this.interpolatedZoneRadius;
-        const renderCenter = this.interpolatedZoneCenter;
+        const renderCenterX = this.interpolatedZoneCenterX;
+        const renderCenterY = this.interpolatedZoneCenterY;
 
-        this.renderZoneCircle(renderCenter, renderRadius);
-        this.renderZoneIndicator(renderCenter, renderRadius, playerPos);
-
-        if (zoneRadius !== this.lastZoneRadius || zoneCenter !== this.lastZoneCenter) {
-            this.lastZoneCenter = zoneCenter;
-            this.lastZoneRadius = zoneRadius;
-        }
+        this.renderZoneCircle(renderCenterX, renderCenterY, renderRadius);
+        this.renderZoneIndicator(renderCenterX, renderCenterY, renderRadius, playerPos);
     }
 
-    renderZoneCircle(renderCenter, renderRadius) {
+    renderZoneCircle(renderCenterX, renderCenterY, renderRadius) {
         const needsRedraw = Math.abs(renderRadius - this.lastRenderedZoneRadius) > 0.1 ||
-            Math.abs(renderCenter - this.lastRenderedZoneCenter) > 0.1;
+            Math.abs(renderCenterX - this.lastRenderedZoneCenterX) > 0.1 ||
+            Math.abs(renderCenterY - this.lastRenderedZoneCenterY) > 0.1;
 
         if (needsRedraw) {
             this.zoneGraphics.clear();
 
             const buffer = 10000;
             const worldSize = (renderRadius + buffer) * 2;
-            const worldOffset = renderCenter - renderRadius - buffer;
+            const worldOffsetX = renderCenterX - renderRadius - buffer;
+            const worldOffsetY = renderCenterY - renderRadius - buffer;
 
             this.zoneGraphics.beginFill(0x90EE90, 1.0);
-            this.zoneGraphics.drawRect(worldOffset, worldOffset, worldSize, worldSize);
+            this.zoneGraphics.drawRect(worldOffsetX, worldOffsetY, worldSize, worldSize);
             this.zoneGraphics.endFill();
 
             this.zoneGraphics.beginFill(0xFF0000, 0.4);
-            this.zoneGraphics.drawRect(worldOffset, worldOffset, worldSize, worldSize);
+            this.zoneGraphics.drawRect(worldOffsetX, worldOffsetY, worldSize, worldSize);
             this.zoneGraphics.endFill();
 
             this.zoneGraphics.beginFill(0x90EE90, 1.0);
-            this.zoneGraphics.drawCircle(renderCenter, renderCenter, renderRadius);
+            this.zoneGraphics.drawCircle(renderCenterX, renderCenterY, renderRadius);
             this.zoneGraphics.endFill();
 
             this.zoneGraphics.lineStyle(2, 0x00FF00, 0.6);
-            this.zoneGraphics.drawCircle(renderCenter, renderCenter, renderRadius);
+            this.zoneGraphics.drawCircle(renderCenterX, renderCenterY, renderRadius);
             this.zoneGraphics.lineStyle(0);
 
-            this.lastRenderedZoneCenter = renderCenter;
+            this.lastRenderedZoneCenterX = renderCenterX;
+            this.lastRenderedZoneCenterY = renderCenterY;
             this.lastRenderedZoneRadius = renderRadius;
         }
     }
 
-    renderZoneIndicator(renderCenter, renderRadius, playerPos) {
+    renderZoneIndicator(renderCenterX, renderCenterY, renderRadius, playerPos) {
         if (!this.zoneIndicatorGraphics) return;
 
         const renderX = playerPos.x;
         const renderY = playerPos.y;
 
-        const dx = renderCenter - renderX;
-        const dy = renderCenter - renderY;
+        const dx = renderCenterX - renderX;
+        const dy = renderCenterY - renderY;
         const distanceToCenter = Math.sqrt(dx * dx + dy * dy);
         const distanceToZone = distanceToCenter - renderRadius;
         const minDistance = 40.0;
@@ -115,8 +117,8 @@ export class ZoneRenderer {
                 this.zoneIndicatorGraphics.clear();
                 this.zoneIndicatorHasArrow = false;
 
-                const nearestZoneX = renderCenter + Math.cos(angleToCenter) * renderRadius;
-                const nearestZoneY = renderCenter + Math.sin(angleToCenter) * renderRadius;
+                const nearestZoneX = renderCenterX + Math.cos(angleToCenter) * renderRadius;
+                const nearestZoneY = renderCenterY + Math.sin(angleToCenter) * renderRadius;
 
                 const dirX = nearestZoneX - renderX;
                 const dirY = nearestZoneY - renderY;
@@ -126,7 +126,9 @@ export class ScreenManager {
             ammoPickups: {},
             weaponPickups: {},
             healthPickups: {},
-            zoneCenter: 0,
+            armorPickups: {},
+            zoneCenterX: 0,
+            zoneCenterY: 0,
             zoneRadius: 3200,
             gameTime: 0,
             phase: 'lobby'
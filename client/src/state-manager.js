@@ -1,3 +1,7 @@
+import { applyMovementWithCollisions } from './collision-system.js';
+
+const TICK_RATE = 20;
+
 export class StateManager {
     constructor(game) {
         this.game = game;
@@ -89,6 +93,27 @@ export class StateManager {
 
         this.ensureStateStructure(this.game.gameState);
 
+        if (diff.tick !== undefined) {
+            this.game.serverTick = diff.tick;
+        }
+
+        if (diff.lastProcessedInput !== undefined && diff.lastProcessedInput > this.game.lastAcknowledgedInputId) {
+            this.game.lastAcknowledgedInputId = diff.lastProcessedInput;
+            this.game.pendingInputs = this.game.pendingInputs.filter(input => input.id > diff.lastProcessedInput);
+        }
+
+        if (diff.impacts) {
+            for (const impact of diff.impacts) {
+                this.game.bulletImpacts.set(impact.bulletId, impact);
+            }
+            if (this.game.bulletImpacts.size > 200) {
+                const stale = Array.from(this.game.bulletImpacts.keys()).slice(0, 100);
+                for (const id of stale) {
+                    this.game.bulletImpacts.delete(id);
+                }
+            }
+        }
+
         if (diff.players) {
             for (const [id, player] of Object.entries(diff.players)) {
                 this.game.gameState.players[id] = player;
@@ -169,11 +194,32 @@ export class StateManager {
             }
         }
 
-        if (diff.zoneCenter !== undefined) {
-            this.game.gameState.zoneCenter = diff.zoneCenter;
+        if (diff.armorPickups) {
+            if (!this.game.gameState.armorPickups) {
+                this.game.gameState.armorPickups = {};
+            }
+            for (const [id, armor] of Object.entries(diff.armorPickups)) {
+                this.game.gameState.armorPickups[id] = armor;
+            }
         }
-        if (diff.zoneRadius !== undefined) {
-            this.game.gameState.zoneRadius = diff.zoneRadius;
+
+        if (diff.removedArmor && this.game.gameState.armorPickups) {
+            for (const id of diff.removedArmor) {
+                delete this.game.gameState.armorPickups[id];
+            }
+        }
+
+        if (diff.zone) {
+            const zone = diff.zone;
+            this.game.gameState.zoneCenterX = zone.centerX;
+            this.game.gameState.zoneCenterY = zone.centerY;
+            this.game.gameState.zoneRadius = zone.radius;
+            this.game.gameState.nextZoneX = zone.nextX;
+            this.game.gameState.nextZoneY = zone.nextY;
+            this.game.gameState.nextZoneRadius = zone.nextRadius;
+            this.game.gameState.zoneStage = zone.stage;
+            this.game.gameState.zoneState = zone.state;
+            this.game.gameState.zoneStateEndTick = zone.stateEndTick;
         }
         if (diff.gameTime !== undefined) {
             this.game.gameState.gameTime = diff.gameTime;
@@ -181,13 +227,51 @@ export class StateManager {
         if (diff.phase !== undefined) {
             this.game.gameState.phase = diff.phase;
         }
+        if (diff.phaseEndTick !== undefined) {
+            this.game.gameState.phaseEndTick = diff.phaseEndTick;
+        }
+        if (diff.matchId !== undefined) {
+            this.game.gameState.matchId = diff.matchId;
+        }
         if (diff.winner !== undefined) {
             this.game.gameState.winner = diff.winner;
         }
+        if (diff.inventory !== undefined) {
+            this.game.gameState.inventory = diff.inventory;
+        }
 
         this.updateGameState(this.game.gameState);
     }
 
+    // The server position reflects every input up to lastAcknowledgedInputId.
+    // Re-applying the inputs it has not processed yet gives the position the
+    // prediction should be at right now.
+    replayPendingInputs(serverPlayer, state) {
+        let x = serverPlayer.x;
+        let y = serverPlayer.y;
+        const pending = this.game.pendingInputs;
+        if (!pending.length || !serverPlayer.velocity) {
+            return { x, y };
+        }
+
+        const checkRadius = 400;
+        const buildings = (state.buildings ?? []).filter(b =>
+            Math.abs(b.x - x) < checkRadius && Math.abs(b.y - y) < checkRadius
+        );
+        const trees = (state.trees ?? []).filter(t =>
+            Math.abs(t.x - x) < checkRadius && Math.abs(t.y - y) < checkRadius
+        );
+        const moveSpeed = serverPlayer.velocity / TICK_RATE;
+
+        for (const input of pending) {
+            if (input.moveX === 0 && input.moveY === 0) continue;
+            const result = applyMovementWithCollisions(x, y, input.moveX, input.moveY, moveSpeed, buildings, trees);
+            x = result.x;
+            y = result.y;
+        }
+        return { x, y };
+    }
+
     updateGameState(state) {
         if (!state) {
             console.error('[updateGameState] Received null/undefined state');
@@ -241,19 +325,18 @@ export class StateManager {
                     this.game.clientPrediction.angle = serverPlayer.angle;
                 }
 
-                const dx = this.game.clientPrediction.x - serverPlayer.x;
-                const dy = this.game.clientPrediction.y - serverPlayer.y;
+                const target = this.replayPendingInputs(serverPlayer, state);
+                const dx = this.game.clientPrediction.x - target.x;
+                const dy = this.game.clientPrediction.y - target.y;
                 const errorSq = dx * dx + dy * dy;
 
                 if (errorSq > HARD_SNAP_THRESHOLD_SQ) {
-                    this.game.clientPrediction.x = serverPlayer.x;
-                    this.game.clientPrediction.y = serverPlayer.y;
-                    this.game.pendingInputs = [];
-                    this.game.lastAcknowledgedInputId = -1;
+                    this.game.clientPrediction.x = target.x;
+                    this.game.clientPrediction.y = target.y;
                 } else if (errorSq > SMOOTH_CORRECTION_THRESHOLD_SQ) {
                     const correction = 0.2;
-                    this.game.clientPrediction.x += (serverPlayer.x - this.game.clientPrediction.x) * correction;
-                    this.game.clientPrediction.y += (serverPlayer.y - this.game.clientPrediction.y) * correction;
+                    this.game.clientPrediction.x += (target.x - this.game.clientPrediction.x) * correction;
+                    this.game.clientPrediction.y += (target.y - this.game.clientPrediction.y) * correction;
                 }
             }
 
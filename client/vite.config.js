@@ -7,7 +7,8 @@ export default defineConfig({
             '/ws': {
                 target: 'ws://localhost:12345',
                 ws: true
-            }
+            },
+            '/lobbies': 'http://localhost:12345'
         }
     },
     build: {
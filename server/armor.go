@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+)
+
+type ArmorTier struct {
+	Reduction  float64
+	Durability int
+}
+
+var armorTiers = map[int]ArmorTier{
+	1: {Reduction: 0.30, Durability: 150},
+	2: {Reduction: 0.40, Durability: 250},
+	3: {Reduction: 0.55, Durability: 350},
+}
+
+func randomArmorTier() int {
+	roll := rand.Float64()
+	if roll < 0.6 {
+		return 1
+	} else if roll < 0.9 {
+		return 2
+	}
+	return 3
+}
+
+func (gs *GameServer) spawnArmorPickup(x, y float64, kind string, tier, durability int) {
+	armorID := fmt.Sprintf("armor_%d", gs.nextArmorID)
+	gs.nextArmorID++
+	gs.gameState.ArmorPickups[armorID] = &ArmorPickup{
+		ID:         armorID,
+		X:          roundFloat(x, 2),
+		Y:          roundFloat(y, 2),
+		Kind:       kind,
+		Tier:       tier,
+		Durability: durability,
+		Active:     true,
+	}
+}
+
+// applyArmor resolves a hit against the player's armor and returns the damage
+// that reaches their health. A share of hits land on the head, which hurts
+// more but is covered by the helmet instead of the vest.
+func applyArmor(player *Player, damage int) int {
+	tier := &player.VestTier
+	durability := &player.VestDurability
+	if rand.Float64() < HEADSHOT_CHANCE {
+		damage = int(math.Round(float64(damage) * HEADSHOT_MULTIPLIER))
+		tier = &player.HelmetTier
+		durability = &player.HelmetDurability
+	}
+
+	if *tier == 0 || *durability <= 0 {
+		return damage
+	}
+
+	absorbed := int(math.Round(float64(damage) * armorTiers[*tier].Reduction))
+	*durability -= damage
+	if *durability <= 0 {
+		*tier = 0
+		*durability = 0
+	}
+	return damage - absorbed
+}
+
+func armorBetter(tier, durability, currentTier, currentDurability int) bool {
+	return tier > currentTier || (tier == currentTier && durability > currentDurability)
+}
+
+// pickUpArmor equips the armor if it beats what the player is wearing,
+// leaving the old piece on the ground. It reports whether the pickup was
+// consumed.
+func (gs *GameServer) pickUpArmor(player *Player, armor *ArmorPickup) bool {
+	tier := &player.VestTier
+	durability := &player.VestDurability
+	if armor.Kind == ARMOR_HELMET {
+		tier = &player.HelmetTier
+		durability = &player.HelmetDurability
+	}
+
+	if !armorBetter(armor.Tier, armor.Durability, *tier, *durability) {
+		return false
+	}
+
+	if *tier > 0 {
+		gs.spawnArmorPickup(player.X, player.Y, armor.Kind, *tier, *durability)
+	}
+	*tier = armor.Tier
+	*durability = armor.Durability
+	log.Printf("[PICKUP] Player %s equipped %s tier %d (%d durability)", player.ID, armor.Kind, armor.Tier, armor.Durability)
+	return true
+}
+
+func resetArmor(player *Player) {
+	player.VestTier = 0
+	player.VestDurability = 0
+	player.HelmetTier = 0
+	player.HelmetDurability = 0
+}
@@ -3,198 +3,205 @@ package main
 import (
 	"math"
 	"math/rand"
-	"strings"
-	"time"
 )
 
-func (gs *GameServer) updateBots(tick int) {
-	for enemyID, enemy := range gs.gameState.Players {
-		if !strings.HasPrefix(enemyID, "enemy_") || !enemy.Alive {
+// Bots think in the same terms as human clients: each tick a brain looks at
+// what its bot can perceive and decides on movement, aim and trigger. The
+// result is queued as ordinary inputs, so bots move and shoot through
+// processQueuedInputs exactly like players do.
+
+const BOT_AMMO_SEARCH_RANGE = 600.0
+
+// BotInput is one tick's decision, the same fields a client's input message
+// carries.
+type BotInput struct {
+	MoveX float64
+	MoveY float64
+	Angle float64
+	Shoot bool
+}
+
+// BotView is what a bot perceives on a tick. Self is a copy; brains must not
+// modify the world through the view.
+type BotView struct {
+	Tick       int
+	Self       Player
+	Difficulty *BotDifficulty
+
+	// Living players within the bot's detection range, bots included.
+	Players []*Player
+	// Active ammo pickups within BOT_AMMO_SEARCH_RANGE.
+	AmmoPickups []*AmmoPickup
+
+	ZoneX          float64
+	ZoneY          float64
+	ZoneRadius     float64
+	NextZoneX      float64
+	NextZoneY      float64
+	NextZoneRadius float64
+}
+
+type BotBrain interface {
+	Name() string
+	Think(view *BotView) BotInput
+}
+
+var botBrains = []struct {
+	Name   string
+	Weight float64
+	New    func() BotBrain
+}{
+	{Name: "hunter", Weight: 0.7, New: func() BotBrain { return &hunterBrain{} }},
+	{Name: "camper", Weight: 0.3, New: func() BotBrain { return &camperBrain{} }},
+}
+
+func randomBotBrain() BotBrain {
+	roll := rand.Float64()
+	for _, brain := range botBrains {
+		roll -= brain.Weight
+		if roll < 0 {
+			return brain.New()
+		}
+	}
+	return botBrains[len(botBrains)-1].New()
+}
+
+// perceive builds the view for one bot. Callers must hold gs.mu.
+func (gs *GameServer) perceive(bot *Player, difficulty *BotDifficulty, tick int) *BotView {
+	view := &BotView{
+		Tick:           tick,
+		Self:           *bot,
+		Difficulty:     difficulty,
+		ZoneX:          gs.gameState.ZoneCenterX,
+		ZoneY:          gs.gameState.ZoneCenterY,
+		ZoneRadius:     gs.gameState.ZoneRadius,
+		NextZoneX:      gs.gameState.NextZoneX,
+		NextZoneY:      gs.gameState.NextZoneY,
+		NextZoneRadius: gs.gameState.NextZoneRadius,
+	}
+
+	rangeSq := difficulty.DetectionRange * difficulty.DetectionRange
+	for _, player := range gs.gameState.Players {
+		if player.ID == bot.ID || !player.Alive {
 			continue
 		}
+		dx := player.X - bot.X
+		dy := player.Y - bot.Y
+		if dx*dx+dy*dy <= rangeSq {
+			view.Players = append(view.Players, player)
+		}
+	}
 
-		botState := gs.botStates[enemyID]
-		if botState == nil {
-			botState = &BotState{
-				TargetX:       enemy.X,
-				TargetY:       enemy.Y,
-				LastDirChange: 0,
-				MoveAngle:     rand.Float64() * 2 * math.Pi,
-			}
-			gs.botStates[enemyID] = botState
+	const ammoRangeSq = BOT_AMMO_SEARCH_RANGE * BOT_AMMO_SEARCH_RANGE
+	for _, ammo := range gs.gameState.AmmoPickups {
+		if !ammo.Active {
+			continue
 		}
+		dx := ammo.X - bot.X
+		dy := ammo.Y - bot.Y
+		if dx*dx+dy*dy <= ammoRangeSq {
+			view.AmmoPickups = append(view.AmmoPickups, ammo)
+		}
+	}
 
-		const detectionRange = 400.0
-		var targetPlayer *Player
-		var minDist float64 = detectionRange
-		var targetAmmo *AmmoPickup
-		var minAmmoDist float64 = 600.0
-
-		if enemy.Ammo <= 10 {
-			for _, ammo := range gs.gameState.AmmoPickups {
-				if !ammo.Active {
-					continue
-				}
-				dx := ammo.X - enemy.X
-				dy := ammo.Y - enemy.Y
-				dist := math.Sqrt(dx*dx + dy*dy)
-				if dist < minAmmoDist {
-					minAmmoDist = dist
-					targetAmmo = ammo
-				}
-			}
+	return view
+}
+
+// updateBots lets every living bot think and buffers its decision as inputs
+// for the next tick. processQueuedInputs picks them up, since it must take
+// inputQueueMu before gs.mu. Callers must hold gs.mu.
+func (gs *GameServer) updateBots(tick int) {
+	for botID, state := range gs.botStates {
+		bot := gs.gameState.Players[botID]
+		if bot == nil || !bot.Alive {
+			continue
 		}
 
-		if targetAmmo == nil {
-			for _, player := range gs.gameState.Players {
-				if player.ID == enemyID || !player.Alive || strings.HasPrefix(player.ID, "enemy_") {
-					continue
-				}
-				dx := player.X - enemy.X
-				dy := player.Y - enemy.Y
-				dist := math.Sqrt(dx*dx + dy*dy)
-				if dist < minDist {
-					minDist = dist
-					targetPlayer = player
-				}
-			}
+		input := state.Brain.Think(gs.perceive(bot, state.Difficulty, tick))
+
+		// Shots go first so they are fired on the same tick as the move.
+		if input.Shoot {
+			gs.botInputs = append(gs.botInputs, QueuedInput{
+				PlayerID: botID,
+				Shoot:    true,
+				Angle:    input.Angle,
+				Tick:     tick + 1,
+			})
 		}
 
-		var moveX, moveY float64
-		var targetAngle float64
-
-		if targetAmmo != nil {
-			dx := targetAmmo.X - enemy.X
-			dy := targetAmmo.Y - enemy.Y
-			dist := math.Sqrt(dx*dx + dy*dy)
-			if dist > 0 {
-				moveX = dx / dist
-				moveY = dy / dist
-				targetAngle = math.Atan2(dy, dx)
-			}
-			enemy.Angle = roundFloat(targetAngle, 4)
-		} else if targetPlayer != nil {
-			dx := targetPlayer.X - enemy.X
-			dy := targetPlayer.Y - enemy.Y
-			dist := math.Sqrt(dx*dx + dy*dy)
-			if dist > 0 {
-				moveX = dx / dist
-				moveY = dy / dist
-				targetAngle = math.Atan2(dy, dx)
-			}
-
-			enemy.Angle = roundFloat(targetAngle, 4)
-
-			if dist < 300 && enemy.Ammo > 0 {
-				now := time.Now().UnixMilli()
-				weapon := GetWeapon(enemy.Weapon)
-				if now-enemy.LastShoot >= weapon.GetCooldown() {
-					gs.createBullet(enemy)
-				}
-			}
+		// Like a client, the bot reports where it expects to end up.
+		step := bot.Velocity / TICK_RATE
+		gs.botInputs = append(gs.botInputs, QueuedInput{
+			PlayerID: botID,
+			MoveX:    input.MoveX,
+			MoveY:    input.MoveY,
+			Angle:    input.Angle,
+			Tick:     tick + 1,
+			ClientX:  bot.X + input.MoveX*step,
+			ClientY:  bot.Y + input.MoveY*step,
+		})
+	}
+}
+
+// queueBotInputs moves the bots' buffered inputs into the input queue.
+// Callers must hold inputQueueMu.
+func (gs *GameServer) queueBotInputs() {
+	gs.mu.Lock()
+	inputs := gs.botInputs
+	gs.botInputs = nil
+	gs.mu.Unlock()
+
+	for _, input := range inputs {
+		if input.Shoot {
+			gs.shotQueue[input.PlayerID] = append(gs.shotQueue[input.PlayerID], input)
 		} else {
-			if tick-botState.LastDirChange > 60 {
-				botState.MoveAngle = rand.Float64() * 2 * math.Pi
-				botState.LastDirChange = tick
-			}
-			moveX = math.Cos(botState.MoveAngle)
-			moveY = math.Sin(botState.MoveAngle)
-			targetAngle = botState.MoveAngle
+			gs.inputQueue[input.PlayerID] = append(gs.inputQueue[input.PlayerID], input)
 		}
+	}
+}
 
-		if moveX != 0 || moveY != 0 {
-			len := math.Sqrt(moveX*moveX + moveY*moveY)
-			if len > 1.0 {
-				moveX /= len
-				moveY /= len
-			}
-
-			deltaTime := 1.0 / TICK_RATE
-			moveSpeed := enemy.Velocity * deltaTime
-
-			oldX := enemy.X
-			oldY := enemy.Y
-
-			newX := enemy.X + moveX*moveSpeed
-			newY := enemy.Y + moveY*moveSpeed
-
-			PLAYER_RADIUS := 8.0
-
-			canMoveX := true
-			canMoveY := true
-
-			gs.ensureChunksAroundPlayer(newX, enemy.Y)
-
-			testX := newX
-			testY := enemy.Y
-
-			nearbyBuildingsX := gs.buildingGrid.GetNearby(testX, testY, 100)
-			for _, entity := range nearbyBuildingsX {
-				building := entity.(Building)
-				if testX+PLAYER_RADIUS >= building.X && testX-PLAYER_RADIUS <= building.X+building.Width &&
-					testY+PLAYER_RADIUS >= building.Y && testY-PLAYER_RADIUS <= building.Y+building.Height {
-					canMoveX = false
-					break
-				}
-			}
-
-			if canMoveX {
-				nearbyTreesX := gs.treeGrid.GetNearby(testX, testY, 50)
-				for _, entity := range nearbyTreesX {
-					tree := entity.(Tree)
-					dx := testX - tree.X
-					dy := testY - tree.Y
-					dist := math.Sqrt(dx*dx + dy*dy)
-					if dist < tree.Size+PLAYER_RADIUS {
-						canMoveX = false
-						break
-					}
-				}
-			}
-
-			if canMoveX {
-				enemy.X = roundFloat(newX, 2)
-			}
-
-			gs.ensureChunksAroundPlayer(enemy.X, newY)
-
-			testX = enemy.X
-			testY = newY
-
-			nearbyBuildingsY := gs.buildingGrid.GetNearby(testX, testY, 100)
-			for _, entity := range nearbyBuildingsY {
-				building := entity.(Building)
-				if testX+PLAYER_RADIUS >= building.X && testX-PLAYER_RADIUS <= building.X+building.Width &&
-					testY+PLAYER_RADIUS >= building.Y && testY-PLAYER_RADIUS <= building.Y+building.Height {
-					canMoveY = false
-					break
-				}
-			}
-
-			if canMoveY {
-				nearbyTreesY := gs.treeGrid.GetNearby(testX, testY, 50)
-				for _, entity := range nearbyTreesY {
-					tree := entity.(Tree)
-					dx := testX - tree.X
-					dy := testY - tree.Y
-					dist := math.Sqrt(dx*dx + dy*dy)
-					if dist < tree.Size+PLAYER_RADIUS {
-						canMoveY = false
-						break
-					}
-				}
-			}
-
-			if canMoveY {
-				enemy.Y = roundFloat(newY, 2)
-			}
-
-			if oldX == enemy.X && oldY == enemy.Y {
-				botState.MoveAngle = rand.Float64() * 2 * math.Pi
-				botState.LastDirChange = tick
-			}
-		}
+// aimAt returns the unit direction and angle from the bot to (x, y) and the
+// distance between them.
+func aimAt(self *Player, x, y float64) (dirX, dirY, angle, dist float64) {
+	dx := x - self.X
+	dy := y - self.Y
+	dist = math.Sqrt(dx*dx + dy*dy)
+	if dist == 0 {
+		return 0, 0, self.Angle, 0
+	}
+	return dx / dist, dy / dist, math.Atan2(dy, dx), dist
+}
+
+// readyToFire reports whether the bot's weapon is off cooldown once the
+// difficulty's reaction time is added, and it has something to shoot.
+func readyToFire(view *BotView) bool {
+	self := &view.Self
+	if self.Ammo+self.Magazine <= 0 || self.Reloading {
+		return false
+	}
+	cooldown := GetWeapon(self.Weapon).GetCooldown() + view.Difficulty.ReactionTicks
+	return view.Tick-self.LastShoot >= cooldown
+}
 
-		enemy.Angle = targetAngle
+// aimError returns angle with the difficulty's random aiming error applied.
+func aimError(view *BotView, angle float64) float64 {
+	return roundFloat(angle+(rand.Float64()*2-1)*view.Difficulty.AimError, 4)
+}
+
+// nearestHuman returns the closest living human in view, or nil.
+func nearestHuman(view *BotView) *Player {
+	var nearest *Player
+	minDistSq := math.Inf(1)
+	for _, player := range view.Players {
+		if player.IsBot {
+			continue
+		}
+		dx := player.X - view.Self.X
+		dy := player.Y - view.Self.Y
+		if distSq := dx*dx + dy*dy; distSq < minDistSq {
+			minDistSq = distSq
+			nearest = player
+		}
 	}
+	return nearest
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// A camper counts as holding its spot within this distance of it.
+const CAMPER_HOLD_RADIUS = 20.0
+
+// hunterBrain chases the nearest human and shoots once in range. Low on
+// ammo it goes for the nearest pickup first; with nobody around it wanders,
+// turning every second or when it runs into something.
+type hunterBrain struct {
+	wanderAngle float64
+	lastTurn    int
+	lastX       float64
+	lastY       float64
+	moving      bool
+}
+
+func (b *hunterBrain) Name() string {
+	return "hunter"
+}
+
+func (b *hunterBrain) Think(view *BotView) BotInput {
+	self := &view.Self
+	stuck := b.moving && self.X == b.lastX && self.Y == b.lastY
+	b.lastX, b.lastY = self.X, self.Y
+
+	var input BotInput
+	if ammo := nearestAmmo(view); ammo != nil && self.Ammo+self.Magazine <= 10 {
+		input.MoveX, input.MoveY, input.Angle, _ = aimAt(self, ammo.X, ammo.Y)
+	} else if target := nearestHuman(view); target != nil {
+		var dist float64
+		input.MoveX, input.MoveY, input.Angle, dist = aimAt(self, target.X, target.Y)
+		if dist < view.Difficulty.FireRange && readyToFire(view) {
+			input.Shoot = true
+			input.Angle = aimError(view, input.Angle)
+		}
+	} else {
+		if stuck || view.Tick-b.lastTurn > 60 {
+			b.wanderAngle = rand.Float64() * 2 * math.Pi
+			b.lastTurn = view.Tick
+		}
+		input.MoveX = math.Cos(b.wanderAngle)
+		input.MoveY = math.Sin(b.wanderAngle)
+		input.Angle = b.wanderAngle
+	}
+
+	b.moving = input.MoveX != 0 || input.MoveY != 0
+	return input
+}
+
+// camperBrain picks a spot inside the next safe zone and holds it, shooting
+// any human that comes within range. It only moves again when the zone
+// leaves its spot behind.
+type camperBrain struct {
+	spotX    float64
+	spotY    float64
+	hasSpot  bool
+	zoneX    float64
+	zoneY    float64
+	lookTurn int
+	look     float64
+}
+
+func (b *camperBrain) Name() string {
+	return "camper"
+}
+
+func (b *camperBrain) Think(view *BotView) BotInput {
+	self := &view.Self
+
+	if !b.hasSpot || b.zoneX != view.NextZoneX || b.zoneY != view.NextZoneY {
+		angle := rand.Float64() * 2 * math.Pi
+		dist := math.Sqrt(rand.Float64()) * view.NextZoneRadius * 0.8
+		b.spotX = view.NextZoneX + math.Cos(angle)*dist
+		b.spotY = view.NextZoneY + math.Sin(angle)*dist
+		b.zoneX, b.zoneY = view.NextZoneX, view.NextZoneY
+		b.hasSpot = true
+	}
+
+	var input BotInput
+	if dirX, dirY, angle, dist := aimAt(self, b.spotX, b.spotY); dist > CAMPER_HOLD_RADIUS {
+		input.MoveX, input.MoveY, input.Angle = dirX, dirY, angle
+	} else {
+		// Look around while holding position.
+		if view.Tick-b.lookTurn > 90 {
+			b.look = rand.Float64() * 2 * math.Pi
+			b.lookTurn = view.Tick
+		}
+		input.Angle = b.look
+	}
+
+	if target := nearestHuman(view); target != nil {
+		_, _, angle, dist := aimAt(self, target.X, target.Y)
+		input.Angle = angle
+		if dist < view.Difficulty.FireRange && readyToFire(view) {
+			input.Shoot = true
+			input.Angle = aimError(view, angle)
+		}
+	}
+
+	return input
+}
+
+func nearestAmmo(view *BotView) *AmmoPickup {
+	var nearest *AmmoPickup
+	minDistSq := math.Inf(1)
+	for _, ammo := range view.AmmoPickups {
+		dx := ammo.X - view.Self.X
+		dy := ammo.Y - view.Self.Y
+		if distSq := dx*dx + dy*dy; distSq < minDistSq {
+			minDistSq = distSq
+			nearest = ammo
+		}
+	}
+	return nearest
+}
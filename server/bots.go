@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+)
+
+// Bot population. Each room fills itself up to rules.Population players with
+// bots, adding one when a human leaves and retiring one when a human joins.
+// Bots are only retired before the match starts so nobody vanishes mid-fight.
+
+type BotDifficulty struct {
+	Name           string
+	Weight         float64
+	Velocity       float64
+	DetectionRange float64
+	FireRange      float64
+	AimError       float64 // radians of random error added to each shot
+	ReactionTicks  int     // ticks added to the weapon cooldown
+	VestTier       int
+	HelmetTier     int
+}
+
+var botDifficulties = []BotDifficulty{
+	{Name: "easy", Weight: 0.4, Velocity: 16.67, DetectionRange: 300, FireRange: 200, AimError: 0.25, ReactionTicks: 15},
+	{Name: "normal", Weight: 0.4, Velocity: 25, DetectionRange: 400, FireRange: 300, AimError: 0.12, ReactionTicks: 6, VestTier: 1},
+	{Name: "hard", Weight: 0.2, Velocity: 40, DetectionRange: 500, FireRange: 350, AimError: 0.04, VestTier: 2, HelmetTier: 1},
+}
+
+func randomBotDifficulty() *BotDifficulty {
+	roll := rand.Float64()
+	for i := range botDifficulties {
+		roll -= botDifficulties[i].Weight
+		if roll < 0 {
+			return &botDifficulties[i]
+		}
+	}
+	return &botDifficulties[len(botDifficulties)-1]
+}
+
+// balanceBots adds or retires bots until humans plus bots match the room's
+// population. Callers must hold gs.mu.
+func (gs *GameServer) balanceBots() {
+	if gs.gameState.Phase == PHASE_FINISHED {
+		return
+	}
+
+	humans := 0
+	bots := make([]*Player, 0, len(gs.botStates))
+	for _, p := range gs.gameState.Players {
+		if p.IsBot {
+			bots = append(bots, p)
+		} else {
+			humans++
+		}
+	}
+	if humans == 0 {
+		return
+	}
+
+	want := gs.rules.Population - humans
+	if want < 0 {
+		want = 0
+	}
+
+	for i := len(bots); i < want; i++ {
+		gs.addBot()
+	}
+
+	excess := len(bots) - want
+	if excess > 0 && (gs.gameState.Phase == PHASE_LOBBY || gs.gameState.Phase == PHASE_WARMUP) {
+		// Dead bots go first.
+		sort.Slice(bots, func(i, j int) bool {
+			return !bots[i].Alive && bots[j].Alive
+		})
+		for _, bot := range bots[:excess] {
+			gs.removeBot(bot.ID)
+		}
+	}
+}
+
+// addBot spawns a new bot with a random difficulty. Callers must hold gs.mu.
+func (gs *GameServer) addBot() {
+	botID := fmt.Sprintf("enemy_%d", gs.nextBotID)
+	gs.nextBotID++
+
+	bot := &Player{ID: botID, IsBot: true}
+	state := &BotState{
+		Difficulty: randomBotDifficulty(),
+		Brain:      randomBotBrain(),
+	}
+	gs.gameState.Players[botID] = bot
+	gs.botStates[botID] = state
+	gs.respawnBot(bot, state)
+
+	log.Printf("[BOTS] Added %s (%s %s, %s)", botID, state.Difficulty.Name, state.Brain.Name(), bot.Weapon)
+}
+
+// removeBot retires a bot. Callers must hold gs.mu.
+func (gs *GameServer) removeBot(botID string) {
+	delete(gs.gameState.Players, botID)
+	delete(gs.botStates, botID)
+	log.Printf("[BOTS] Removed %s", botID)
+}
+
+// respawnBots puts every bot back into the zone with a fresh loadout.
+// Callers must hold gs.mu.
+func (gs *GameServer) respawnBots() {
+	for botID, state := range gs.botStates {
+		if bot := gs.gameState.Players[botID]; bot != nil {
+			gs.respawnBot(bot, state)
+		}
+	}
+}
+
+// respawnBot resets a bot and gives it a random weapon from the room's
+// weapon set and the armor of its difficulty.
+func (gs *GameServer) respawnBot(bot *Player, state *BotState) {
+	difficulty := state.Difficulty
+	weapon := gs.rules.Weapons[rand.Intn(len(gs.rules.Weapons))]
+
+	bot.Angle = 0
+	bot.Health = MAX_HEALTH
+	bot.Alive = true
+	bot.Boost = 0
+	bot.Velocity = difficulty.Velocity
+	bot.Ammo = 100
+	bot.Score = 0
+	bot.Kills = 0
+	bot.LastShoot = 0
+	resetInventory(bot, weapon)
+	resetArmor(bot)
+	if difficulty.VestTier > 0 {
+		bot.VestTier = difficulty.VestTier
+		bot.VestDurability = armorTiers[difficulty.VestTier].Durability
+	}
+	if difficulty.HelmetTier > 0 {
+		bot.HelmetTier = difficulty.HelmetTier
+		bot.HelmetDurability = armorTiers[difficulty.HelmetTier].Durability
+	}
+
+	spawnX := (rand.Float64() - 0.5) * 200
+	spawnY := (rand.Float64() - 0.5) * 200
+	var ok bool
+	bot.X, bot.Y, ok = gs.findValidPosition(spawnX, spawnY, PLAYER_RADIUS, 100, true)
+	if !ok {
+		log.Printf("Warning: Could not find valid spawn position for bot %s", bot.ID)
+	}
+}
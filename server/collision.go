@@ -0,0 +1,66 @@
+package main
+
+import "math"
+
+// segmentHitsAABB returns the fraction t in [0, 1] along the segment from
+// (x0, y0) to (x1, y1) where it first enters the box, or false if it misses.
+// A segment that starts inside the box hits at t = 0.
+func segmentHitsAABB(x0, y0, x1, y1, minX, minY, maxX, maxY float64) (float64, bool) {
+	tMin := 0.0
+	tMax := 1.0
+
+	d := [2]float64{x1 - x0, y1 - y0}
+	origin := [2]float64{x0, y0}
+	lo := [2]float64{minX, minY}
+	hi := [2]float64{maxX, maxY}
+
+	for axis := 0; axis < 2; axis++ {
+		if d[axis] == 0 {
+			if origin[axis] < lo[axis] || origin[axis] > hi[axis] {
+				return 0, false
+			}
+			continue
+		}
+		t1 := (lo[axis] - origin[axis]) / d[axis]
+		t2 := (hi[axis] - origin[axis]) / d[axis]
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		tMin = math.Max(tMin, t1)
+		tMax = math.Min(tMax, t2)
+		if tMin > tMax {
+			return 0, false
+		}
+	}
+	return tMin, true
+}
+
+// segmentHitsCircle returns the fraction t in [0, 1] along the segment from
+// (x0, y0) to (x1, y1) where it first touches the circle, or false if it
+// misses. A segment that starts inside the circle hits at t = 0.
+func segmentHitsCircle(x0, y0, x1, y1, cx, cy, radius float64) (float64, bool) {
+	fx := x0 - cx
+	fy := y0 - cy
+	c := fx*fx + fy*fy - radius*radius
+	if c <= 0 {
+		return 0, true
+	}
+
+	dx := x1 - x0
+	dy := y1 - y0
+	a := dx*dx + dy*dy
+	if a == 0 {
+		return 0, false
+	}
+	b := 2 * (fx*dx + fy*dy)
+	disc := b*b - 4*a*c
+	if disc < 0 {
+		return 0, false
+	}
+
+	t := (-b - math.Sqrt(disc)) / (2 * a)
+	if t < 0 || t > 1 {
+		return 0, false
+	}
+	return t, true
+}
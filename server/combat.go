@@ -1,26 +1,113 @@
 package main
 
 import (
-	"time"
+	"log"
+	"math"
 )
 
-func (gs *GameServer) createBullet(player *Player) {
-	if player.Ammo <= 0 {
+// createBullet fires the player's weapon. rewind is how many ticks behind the
+// server the shooter was looking when they fired; hits are resolved against
+// target positions from that far back.
+func (gs *GameServer) createBullet(player *Player, rewind int) {
+	if player.Weapon == "" || player.Reloading {
+		return
+	}
+
+	if player.Magazine <= 0 {
+		gs.startReload(player)
 		return
 	}
 
 	weapon := GetWeapon(player.Weapon)
-	now := time.Now().UnixMilli()
 
-	if now-player.LastShoot < weapon.GetCooldown() {
+	if gs.currentTick-player.LastShoot < weapon.GetCooldown() {
 		return
 	}
 
-	player.LastShoot = now
-	player.Ammo--
+	player.LastShoot = gs.currentTick
+	player.Magazine--
 
 	bullets := weapon.CreateBullets(player, gs)
 	for _, bullet := range bullets {
+		bullet.Rewind = rewind
 		gs.gameState.Bullets[bullet.ID] = bullet
 	}
+
+	if player.Magazine == 0 {
+		gs.startReload(player)
+	}
+}
+
+func (gs *GameServer) startReload(player *Player) {
+	if player.Weapon == "" {
+		return
+	}
+	weapon := GetWeapon(player.Weapon)
+	if player.Reloading || player.Ammo <= 0 || player.Magazine >= weapon.GetMagazineSize() {
+		return
+	}
+
+	player.Reloading = true
+	player.ReloadStart = gs.currentTick
+	player.ReloadEnd = gs.currentTick + weapon.GetReloadTime()
+	log.Printf("[RELOAD] Player %s reloading %s (%d in magazine, %d reserve)", player.ID, player.Weapon, player.Magazine, player.Ammo)
+}
+
+func cancelReload(player *Player) {
+	player.Reloading = false
+	player.ReloadStart = 0
+	player.ReloadEnd = 0
+}
+
+// fillMagazine moves rounds from the reserve into the magazine of the
+// current weapon, up to its capacity.
+func fillMagazine(player *Player) {
+	if player.Weapon == "" {
+		return
+	}
+	capacity := GetWeapon(player.Weapon).GetMagazineSize()
+	load := capacity - player.Magazine
+	if load > player.Ammo {
+		load = player.Ammo
+	}
+	if load > 0 {
+		player.Magazine += load
+		player.Ammo -= load
+	}
+}
+
+func (gs *GameServer) updateReloads(tick int) {
+	for _, player := range gs.gameState.Players {
+		if !player.Reloading {
+			continue
+		}
+		if !player.Alive {
+			cancelReload(player)
+			continue
+		}
+		if tick >= player.ReloadEnd {
+			cancelReload(player)
+			fillMagazine(player)
+		}
+	}
+}
+
+func reloadProgress(player *Player, tick int) float64 {
+	if !player.Reloading || player.ReloadEnd <= player.ReloadStart {
+		return 0
+	}
+	progress := float64(tick-player.ReloadStart) / float64(player.ReloadEnd-player.ReloadStart)
+	return roundFloat(math.Max(0, math.Min(1, progress)), 2)
+}
+
+func (b *Bullet) damageAt(distance float64) int {
+	factor := 1.0
+	if b.Falloff.End > 0 && distance > b.Falloff.Start {
+		t := 1.0
+		if b.Falloff.End > b.Falloff.Start {
+			t = math.Min(1, (distance-b.Falloff.Start)/(b.Falloff.End-b.Falloff.Start))
+		}
+		factor = 1 - t*(1-b.Falloff.MinFactor)
+	}
+	return int(math.Round(float64(b.Damage) * factor))
 }
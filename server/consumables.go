@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+)
+
+// Consumable describes an item that is channelled for UseTicks before it
+// takes effect. Heal never raises health above HealCap.
+type Consumable struct {
+	Heal     int
+	HealCap  int
+	Boost    float64
+	UseTicks int
+	MaxCarry int
+}
+
+var consumables = map[string]Consumable{
+	ITEM_BANDAGE: {Heal: 150, HealCap: BANDAGE_HEAL_CAP, UseTicks: 4 * TICK_RATE, MaxCarry: 10},
+	ITEM_MEDKIT:  {Heal: MAX_HEALTH, HealCap: MAX_HEALTH, UseTicks: 8 * TICK_RATE, MaxCarry: 3},
+	ITEM_BOOST:   {Boost: 40, UseTicks: 3 * TICK_RATE, MaxCarry: 5},
+}
+
+func randomConsumable() (string, int) {
+	roll := rand.Float64()
+	if roll < 0.55 {
+		return ITEM_BANDAGE, 3
+	} else if roll < 0.8 {
+		return ITEM_BOOST, 1
+	}
+	return ITEM_MEDKIT, 1
+}
+
+// healPlayer adds amount to the player's health without going past limit or
+// MAX_HEALTH. Health that is already above limit is left untouched.
+func healPlayer(player *Player, amount, limit int) {
+	if limit > MAX_HEALTH {
+		limit = MAX_HEALTH
+	}
+	if player.Health >= limit {
+		return
+	}
+	player.Health += amount
+	if player.Health > limit {
+		player.Health = limit
+	}
+}
+
+func (gs *GameServer) spawnConsumablePickup(x, y float64, item string, amount int, droppedBy string) {
+	healthID := fmt.Sprintf("health_%d", gs.nextHealthID)
+	gs.nextHealthID++
+	gs.gameState.HealthPickups[healthID] = &HealthPickup{
+		ID:        healthID,
+		X:         roundFloat(x, 2),
+		Y:         roundFloat(y, 2),
+		Item:      item,
+		Amount:    amount,
+		Active:    true,
+		DroppedBy: droppedBy,
+	}
+}
+
+// pickUpConsumable moves as many items from the pickup into the inventory as
+// the carry limit allows. Like weapons, items the player dropped are left
+// alone until they have walked off them. It reports whether the pickup is
+// now empty.
+func (gs *GameServer) pickUpConsumable(player *Player, pickup *HealthPickup) bool {
+	if pickup.DroppedBy == player.ID {
+		return false
+	}
+	item, ok := consumables[pickup.Item]
+	if !ok {
+		return false
+	}
+	if player.Inventory == nil {
+		player.Inventory = newInventory("")
+	}
+
+	take := item.MaxCarry - player.Inventory.Consumables[pickup.Item]
+	if take <= 0 {
+		return false
+	}
+	if take > pickup.Amount {
+		take = pickup.Amount
+	}
+	player.Inventory.Consumables[pickup.Item] += take
+	pickup.Amount -= take
+	log.Printf("[PICKUP] Player %s collected %d %s at (%.2f, %.2f), carrying %d", player.ID, take, pickup.Item, pickup.X, pickup.Y, player.Inventory.Consumables[pickup.Item])
+	return pickup.Amount == 0
+}
+
+func (gs *GameServer) useItem(player *Player, name string) {
+	item, ok := consumables[name]
+	if !ok || player.Healing != "" || player.Inventory == nil || player.Inventory.Consumables[name] <= 0 {
+		return
+	}
+	if item.Heal > 0 && player.Health >= item.HealCap {
+		return
+	}
+	if item.Boost > 0 && player.Boost >= BOOST_MAX {
+		return
+	}
+
+	cancelReload(player)
+	player.Healing = name
+	player.HealStart = gs.currentTick
+	player.HealEnd = gs.currentTick + item.UseTicks
+	log.Printf("[HEAL] Player %s using %s (health %d)", player.ID, name, player.Health)
+}
+
+func cancelHeal(player *Player) {
+	player.Healing = ""
+	player.HealStart = 0
+	player.HealEnd = 0
+}
+
+// interruptHeal stops a channelled item because the player moved, shot or
+// took damage. The item is only consumed when the channel completes, so
+// nothing is lost.
+func interruptHeal(player *Player, reason string) {
+	if player.Healing == "" {
+		return
+	}
+	log.Printf("[HEAL] Player %s stopped using %s (%s)", player.ID, player.Healing, reason)
+	cancelHeal(player)
+}
+
+func (gs *GameServer) updateConsumables(tick int) {
+	for _, player := range gs.gameState.Players {
+		if !player.Alive {
+			cancelHeal(player)
+			player.Boost = 0
+			continue
+		}
+
+		if player.Healing != "" && tick >= player.HealEnd {
+			name := player.Healing
+			cancelHeal(player)
+			item := consumables[name]
+			if player.Inventory != nil && player.Inventory.Consumables[name] > 0 {
+				player.Inventory.Consumables[name]--
+				if player.Inventory.Consumables[name] == 0 {
+					delete(player.Inventory.Consumables, name)
+				}
+				healPlayer(player, item.Heal, item.HealCap)
+				player.Boost = math.Min(BOOST_MAX, player.Boost+item.Boost)
+				log.Printf("[HEAL] Player %s used %s (health %d, boost %.0f)", player.ID, name, player.Health, player.Boost)
+			}
+		}
+
+		if player.Boost > 0 {
+			if tick%BOOST_REGEN_INTERVAL == 0 {
+				healPlayer(player, 1, MAX_HEALTH)
+			}
+			player.Boost = math.Max(0, player.Boost-BOOST_DECAY_PER_TICK)
+		}
+	}
+}
+
+func healProgress(player *Player, tick int) float64 {
+	if player.Healing == "" || player.HealEnd <= player.HealStart {
+		return 0
+	}
+	progress := float64(tick-player.HealStart) / float64(player.HealEnd-player.HealStart)
+	return roundFloat(math.Max(0, math.Min(1, progress)), 2)
+}
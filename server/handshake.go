@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// PROTOCOL_VERSION changes whenever a message changes shape. Clients older
+// than MIN_PROTOCOL_VERSION are turned away at the handshake.
+const (
+	PROTOCOL_VERSION     = 3
+	MIN_PROTOCOL_VERSION = 3
+	HANDSHAKE_TIMEOUT    = 5 * time.Second
+)
+
+// Features the server can tailor diffs for. Clients list the ones they
+// understand in their hello; anything else is left out of their diffs.
+const (
+	FEATURE_INVENTORY = "inventory"
+	FEATURE_IMPACTS   = "impacts"
+)
+
+var serverFeatures = []string{FEATURE_INVENTORY, FEATURE_IMPACTS}
+
+// Close codes sent when the handshake fails. 4000-4999 are reserved for
+// applications by RFC 6455.
+const (
+	CLOSE_HANDSHAKE_FAILED    = 4000
+	CLOSE_UNSUPPORTED_VERSION = 4001
+)
+
+// HelloMessage is the first message a client sends after connecting.
+type HelloMessage struct {
+	Type            string   `json:"type"`
+	ProtocolVersion int      `json:"protocolVersion"`
+	Encoding        string   `json:"encoding"`
+	Compression     bool     `json:"compression"`
+	Features        []string `json:"features"`
+}
+
+// Capabilities is what the server agreed to after the hello. It is echoed
+// back to the client in the init message.
+type Capabilities struct {
+	ProtocolVersion int      `json:"protocolVersion"`
+	Encoding        string   `json:"encoding"`
+	Compression     bool     `json:"compression"`
+	Features        []string `json:"features"`
+}
+
+var errHandshakeRejected = errors.New("handshake rejected")
+
+// readHello waits for the client's hello and negotiates capabilities. On a
+// bad or incompatible hello the connection is closed with a reason the
+// client can show.
+func readHello(conn *websocket.Conn, r *http.Request) (Capabilities, error) {
+	conn.SetReadDeadline(time.Now().Add(HANDSHAKE_TIMEOUT))
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	var hello HelloMessage
+	if messageType != websocket.TextMessage || json.Unmarshal(data, &hello) != nil || hello.Type != "hello" {
+		rejectConnection(conn, CLOSE_HANDSHAKE_FAILED, "expected hello message")
+		return Capabilities{}, errHandshakeRejected
+	}
+
+	if hello.ProtocolVersion < MIN_PROTOCOL_VERSION || hello.ProtocolVersion > PROTOCOL_VERSION {
+		rejectConnection(conn, CLOSE_UNSUPPORTED_VERSION, fmt.Sprintf(
+			"unsupported protocol version %d, server supports %d-%d",
+			hello.ProtocolVersion, MIN_PROTOCOL_VERSION, PROTOCOL_VERSION))
+		return Capabilities{}, errHandshakeRejected
+	}
+
+	caps := Capabilities{
+		ProtocolVersion: hello.ProtocolVersion,
+		Encoding:        ENCODING_JSON,
+		Features:        []string{},
+	}
+	if hello.Encoding == ENCODING_BINARY {
+		caps.Encoding = ENCODING_BINARY
+	}
+
+	// permessage-deflate is agreed on during the upgrade; the hello only
+	// decides whether we actually compress.
+	if hello.Compression && strings.Contains(r.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		caps.Compression = true
+	}
+	conn.EnableWriteCompression(caps.Compression)
+
+	for _, feature := range hello.Features {
+		for _, supported := range serverFeatures {
+			if feature == supported {
+				caps.Features = append(caps.Features, feature)
+				break
+			}
+		}
+	}
+
+	return caps, nil
+}
+
+func rejectConnection(conn *websocket.Conn, code int, reason string) {
+	log.Printf("[HANDSHAKE] Rejecting %s: %s", conn.RemoteAddr(), reason)
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	conn.Close()
+}
+
+func (c *clientConn) hasFeature(feature string) bool {
+	for _, f := range c.caps.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
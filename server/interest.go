@@ -0,0 +1,116 @@
+package main
+
+import "math"
+
+// Interest management. Every broadcast snapshot copies only the entities in
+// grid cells some client can see and indexes them in a coarse spatial grid;
+// each client then only looks at the cells around it and keeps the resulting
+// view as its baseline for the next diff. Entities that leave a client's view
+// are reported as removed.
+
+type gridCell struct {
+	X, Y int
+}
+
+func interestCell(x, y float64) gridCell {
+	return gridCell{
+		X: int(math.Floor(x / INTEREST_GRID_CELL_SIZE)),
+		Y: int(math.Floor(y / INTEREST_GRID_CELL_SIZE)),
+	}
+}
+
+// interestCells returns every cell a client's interest view can reach. One
+// extra cell of margin covers clients moving between the snapshot and their
+// diff. Callers must hold gs.mu.
+func (gs *GameServer) interestCells() map[gridCell]bool {
+	reach := int(math.Ceil(PLAYER_UPDATE_DISTANCE/INTEREST_GRID_CELL_SIZE)) + 1
+	cells := make(map[gridCell]bool)
+	for _, client := range gs.clients {
+		player := gs.gameState.Players[client.player.ID]
+		if player == nil {
+			continue
+		}
+		center := interestCell(player.X, player.Y)
+		for dx := -reach; dx <= reach; dx++ {
+			for dy := -reach; dy <= reach; dy++ {
+				cells[gridCell{center.X + dx, center.Y + dy}] = true
+			}
+		}
+	}
+	return cells
+}
+
+func (s *DynamicState) indexEntity(x, y float64, entity interface{}) {
+	s.grid.Insert(x, y, entity)
+}
+
+// interestView returns the part of the snapshot relevant to the player at
+// (x, y): other players within PLAYER_UPDATE_DISTANCE, everything else within
+// AOI_RADIUS. Zone and match fields are shared with the snapshot.
+func (s *DynamicState) interestView(playerID string, x, y float64) *DynamicState {
+	view := *s
+	view.Players = make(map[string]*Player)
+	view.Bullets = make(map[string]*Bullet)
+	view.AmmoPickups = make(map[string]*AmmoPickup)
+	view.WeaponPickups = make(map[string]*WeaponPickup)
+	view.HealthPickups = make(map[string]*HealthPickup)
+	view.ArmorPickups = make(map[string]*ArmorPickup)
+	view.Inventories = make(map[string]*Inventory)
+	view.grid = nil
+
+	if self := s.Players[playerID]; self != nil {
+		view.Players[playerID] = self
+	}
+	if inventory := s.Inventories[playerID]; inventory != nil {
+		view.Inventories[playerID] = inventory
+	}
+
+	inRange := func(ex, ey, radiusSq float64) bool {
+		dx := ex - x
+		dy := ey - y
+		return dx*dx+dy*dy <= radiusSq
+	}
+
+	for _, entity := range s.grid.GetNearby(x, y, PLAYER_UPDATE_DISTANCE) {
+		switch e := entity.(type) {
+		case *Player:
+			if inRange(e.X, e.Y, PLAYER_UPDATE_DISTANCE_SQ) {
+				view.Players[e.ID] = e
+			}
+		case *Bullet:
+			if inRange(e.X, e.Y, AOI_RADIUS_SQ) {
+				view.Bullets[e.ID] = e
+			}
+		case *AmmoPickup:
+			if inRange(e.X, e.Y, AOI_RADIUS_SQ) {
+				view.AmmoPickups[e.ID] = e
+			}
+		case *WeaponPickup:
+			if inRange(e.X, e.Y, AOI_RADIUS_SQ) {
+				view.WeaponPickups[e.ID] = e
+			}
+		case *HealthPickup:
+			if inRange(e.X, e.Y, AOI_RADIUS_SQ) {
+				view.HealthPickups[e.ID] = e
+			}
+		case *ArmorPickup:
+			if inRange(e.X, e.Y, AOI_RADIUS_SQ) {
+				view.ArmorPickups[e.ID] = e
+			}
+		}
+	}
+
+	return &view
+}
+
+var emptyView = &DynamicState{}
+
+func removedIDs[T any](current, last map[string]T) []string {
+	removed := make([]string, 0)
+	for id := range last {
+		if _, ok := current[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	return removed
+}
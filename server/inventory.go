@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+func newInventory(weapon string) *Inventory {
+	inv := &Inventory{
+		Slots:       make([]InventorySlot, INVENTORY_WEAPON_SLOTS),
+		Active:      0,
+		Consumables: make(map[string]int),
+	}
+	inv.Slots[0].Weapon = weapon
+	return inv
+}
+
+func (inv *Inventory) copy() *Inventory {
+	if inv == nil {
+		return nil
+	}
+	c := &Inventory{
+		Slots:       make([]InventorySlot, len(inv.Slots)),
+		Active:      inv.Active,
+		Consumables: make(map[string]int, len(inv.Consumables)),
+	}
+	copy(c.Slots, inv.Slots)
+	for name, count := range inv.Consumables {
+		c.Consumables[name] = count
+	}
+	return c
+}
+
+func (inv *Inventory) equals(other *Inventory) bool {
+	if inv == nil || other == nil {
+		return inv == other
+	}
+	if inv.Active != other.Active || len(inv.Slots) != len(other.Slots) || len(inv.Consumables) != len(other.Consumables) {
+		return false
+	}
+	for i := range inv.Slots {
+		if inv.Slots[i] != other.Slots[i] {
+			return false
+		}
+	}
+	for name, count := range inv.Consumables {
+		if other.Consumables[name] != count {
+			return false
+		}
+	}
+	return true
+}
+
+func (inv *Inventory) findWeapon(weapon string) int {
+	for i, slot := range inv.Slots {
+		if slot.Weapon == weapon {
+			return i
+		}
+	}
+	return -1
+}
+
+// resetInventory gives the player a fresh loadout with weapon in the first
+// slot and a full magazine.
+func resetInventory(player *Player, weapon string) {
+	cancelReload(player)
+	cancelHeal(player)
+	player.Inventory = newInventory(weapon)
+	player.Weapon = weapon
+	player.Magazine = 0
+	fillMagazine(player)
+	syncActiveSlot(player)
+}
+
+// syncActiveSlot writes the player's active weapon state back into the
+// inventory. Player.Weapon and Player.Magazine are authoritative for the
+// active slot.
+func syncActiveSlot(player *Player) {
+	if player.Inventory == nil {
+		return
+	}
+	player.Inventory.Slots[player.Inventory.Active] = InventorySlot{
+		Weapon:   player.Weapon,
+		Magazine: player.Magazine,
+	}
+}
+
+// snapshotInventory returns a copy of the player's inventory with the active
+// slot reflecting the current weapon state.
+func snapshotInventory(player *Player) *Inventory {
+	inv := player.Inventory.copy()
+	if inv != nil {
+		inv.Slots[inv.Active] = InventorySlot{Weapon: player.Weapon, Magazine: player.Magazine}
+	}
+	return inv
+}
+
+func (gs *GameServer) switchWeapon(player *Player, slot int) {
+	inv := player.Inventory
+	if inv == nil || slot < 0 || slot >= len(inv.Slots) || slot == inv.Active {
+		return
+	}
+
+	syncActiveSlot(player)
+	cancelReload(player)
+	inv.Active = slot
+	player.Weapon = inv.Slots[slot].Weapon
+	player.Magazine = inv.Slots[slot].Magazine
+	log.Printf("[INVENTORY] Player %s switched to slot %d (%s)", player.ID, slot, player.Weapon)
+}
+
+func (gs *GameServer) dropItem(player *Player, item string, slot int) {
+	inv := player.Inventory
+	if inv == nil {
+		return
+	}
+
+	if item == "" || item == "weapon" {
+		if slot < 0 || slot >= len(inv.Slots) {
+			return
+		}
+		syncActiveSlot(player)
+		if inv.Slots[slot].Weapon == "" {
+			return
+		}
+		gs.dropWeapon(player.X, player.Y, inv.Slots[slot], player.ID)
+		inv.Slots[slot] = InventorySlot{}
+		if slot == inv.Active {
+			cancelReload(player)
+			player.Weapon = ""
+			player.Magazine = 0
+		}
+		return
+	}
+
+	if count := inv.Consumables[item]; count > 0 {
+		if player.Healing == item {
+			cancelHeal(player)
+		}
+		gs.spawnConsumablePickup(player.X, player.Y, item, count, player.ID)
+		delete(inv.Consumables, item)
+		log.Printf("[INVENTORY] Player %s dropped %d %s at (%.2f, %.2f)", player.ID, count, item, player.X, player.Y)
+		return
+	}
+
+	log.Printf("[INVENTORY] Player %s tried to drop unknown item %q", player.ID, item)
+}
+
+func (gs *GameServer) dropWeapon(x, y float64, slot InventorySlot, droppedBy string) {
+	weaponID := fmt.Sprintf("weapon_%d", gs.nextAmmoID)
+	gs.nextAmmoID++
+	gs.gameState.WeaponPickups[weaponID] = &WeaponPickup{
+		ID:        weaponID,
+		X:         roundFloat(x, 2),
+		Y:         roundFloat(y, 2),
+		Weapon:    slot.Weapon,
+		Active:    true,
+		Ammo:      slot.Magazine,
+		DroppedBy: droppedBy,
+	}
+	log.Printf("[INVENTORY] Player %s dropped %s (%d rounds) at (%.2f, %.2f)", droppedBy, slot.Weapon, slot.Magazine, x, y)
+}
+
+// pickUpWeapon stores the weapon in a free slot, tops up ammo if the player
+// already carries it, or swaps it with the active weapon when all slots are
+// taken. A weapon the player dropped is left alone until they have walked
+// off it, so standing on it doesn't swap back and forth. It reports whether
+// the pickup was consumed.
+func (gs *GameServer) pickUpWeapon(player *Player, pickup *WeaponPickup) bool {
+	if pickup.DroppedBy == player.ID {
+		return false
+	}
+
+	inv := player.Inventory
+	if inv == nil {
+		inv = newInventory("")
+		player.Inventory = inv
+	}
+	syncActiveSlot(player)
+
+	if inv.findWeapon(pickup.Weapon) >= 0 {
+		player.Ammo += pickup.Ammo
+		return true
+	}
+
+	slot := inv.findWeapon("")
+	if slot < 0 {
+		slot = inv.Active
+		gs.dropWeapon(player.X, player.Y, inv.Slots[slot], player.ID)
+	}
+
+	magazine := pickup.Ammo
+	if capacity := GetWeapon(pickup.Weapon).GetMagazineSize(); magazine > capacity {
+		magazine = capacity
+	}
+	player.Ammo += pickup.Ammo - magazine
+	inv.Slots[slot] = InventorySlot{Weapon: pickup.Weapon, Magazine: magazine}
+
+	if slot == inv.Active || player.Weapon == "" {
+		cancelReload(player)
+		inv.Active = slot
+		player.Weapon = pickup.Weapon
+		player.Magazine = magazine
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package main
+
+// PositionSnapshot is where a player was at the end of a tick.
+type PositionSnapshot struct {
+	Tick  int
+	X     float64
+	Y     float64
+	Alive bool
+}
+
+// PositionHistory is a fixed-size ring buffer of a player's recent positions,
+// used to rewind targets when resolving hits for lagged shooters.
+type PositionHistory struct {
+	entries [LAG_COMP_HISTORY_TICKS]PositionSnapshot
+	next    int
+	count   int
+}
+
+func (h *PositionHistory) record(tick int, x, y float64, alive bool) {
+	h.entries[h.next] = PositionSnapshot{Tick: tick, X: x, Y: y, Alive: alive}
+	h.next = (h.next + 1) % LAG_COMP_HISTORY_TICKS
+	if h.count < LAG_COMP_HISTORY_TICKS {
+		h.count++
+	}
+}
+
+// at returns the newest snapshot taken at or before tick.
+func (h *PositionHistory) at(tick int) (PositionSnapshot, bool) {
+	for i := 1; i <= h.count; i++ {
+		idx := (h.next - i + LAG_COMP_HISTORY_TICKS) % LAG_COMP_HISTORY_TICKS
+		if h.entries[idx].Tick <= tick {
+			return h.entries[idx], true
+		}
+	}
+	return PositionSnapshot{}, false
+}
+
+func (gs *GameServer) recordPositionHistory(tick int) {
+	for id, player := range gs.gameState.Players {
+		history := gs.positionHistory[id]
+		if history == nil {
+			history = &PositionHistory{}
+			gs.positionHistory[id] = history
+		}
+		history.record(tick, player.X, player.Y, player.Alive)
+	}
+	for id := range gs.positionHistory {
+		if gs.gameState.Players[id] == nil {
+			delete(gs.positionHistory, id)
+		}
+	}
+}
+
+// rewindTicks converts the tick a client says it fired at into how many ticks
+// hit detection should look back, clamped to LAG_COMP_MAX_REWIND_TICKS.
+func (gs *GameServer) rewindTicks(clientTick int) int {
+	if clientTick <= 0 || clientTick >= gs.currentTick {
+		return 0
+	}
+	rewind := gs.currentTick - clientTick
+	if rewind > LAG_COMP_MAX_REWIND_TICKS {
+		rewind = LAG_COMP_MAX_REWIND_TICKS
+	}
+	return rewind
+}
+
+// targetPosition returns where the bullet's shooter saw player on this tick.
+// Bullets without rewind, and players without history, use the live position.
+func (gs *GameServer) targetPosition(player *Player, bullet *Bullet, tick int) (float64, float64, bool) {
+	if bullet.Rewind == 0 {
+		return player.X, player.Y, player.Alive
+	}
+	history := gs.positionHistory[player.ID]
+	if history == nil {
+		return player.X, player.Y, player.Alive
+	}
+	snapshot, ok := history.at(tick - bullet.Rewind)
+	if !ok {
+		return player.X, player.Y, player.Alive
+	}
+	return snapshot.X, snapshot.Y, snapshot.Alive && player.Alive
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Private lobbies are rooms that can only be joined with an invite code. The
+// first player in becomes the host, who sets the rules, starts the match and
+// can kick players. Lobby rooms stay open longer when empty so the code
+// survives everyone reloading, but a lobby nobody ever joins is closed soon.
+// At most LOBBY_MAX_OPEN lobbies can be open at once.
+const (
+	LOBBY_CODE_LENGTH      = 6
+	LOBBY_CODE_ALPHABET    = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	LOBBY_EMPTY_TIMEOUT    = 5 * time.Minute
+	LOBBY_UNJOINED_TIMEOUT = 1 * time.Minute
+	LOBBY_MAX_OPEN         = 64
+	LOBBY_MAX_POPULATION   = 32
+	LOBBY_MIN_ZONE         = 0.5
+	LOBBY_MAX_ZONE         = 3.0
+
+	CLOSE_UNKNOWN_LOBBY = 4002
+	CLOSE_LOBBY_CLOSED  = 4003
+	CLOSE_KICKED        = 4004
+)
+
+// MatchRules are the settings a room plays with. Public rooms use
+// defaultRules; a lobby host can change them before the match starts.
+type MatchRules struct {
+	Population int      `json:"population"`
+	ZoneSpeed  float64  `json:"zoneSpeed"`
+	Weapons    []string `json:"weapons"`
+}
+
+// Kicks are remembered by session ID.
+type Lobby struct {
+	Code           string
+	HostID         string
+	joined         bool
+	startRequested bool
+	kicked         map[string]bool
+}
+
+type LobbyMessage struct {
+	Type             string     `json:"type"`
+	Code             string     `json:"code"`
+	Host             string     `json:"host"`
+	Rules            MatchRules `json:"rules"`
+	AvailableWeapons []string   `json:"availableWeapons"`
+	Players          []string   `json:"players"`
+}
+
+func defaultRules(population int) MatchRules {
+	return MatchRules{
+		Population: population,
+		ZoneSpeed:  1,
+		Weapons:    append([]string{}, weaponNames...),
+	}
+}
+
+// validate clamps the rules to what a lobby may choose. Unknown weapons are
+// dropped; an empty weapon set means every weapon.
+func (r MatchRules) validate() MatchRules {
+	if r.Population < 0 {
+		r.Population = 0
+	} else if r.Population > LOBBY_MAX_POPULATION {
+		r.Population = LOBBY_MAX_POPULATION
+	}
+	if r.ZoneSpeed == 0 || math.IsNaN(r.ZoneSpeed) {
+		r.ZoneSpeed = 1
+	}
+	r.ZoneSpeed = math.Max(LOBBY_MIN_ZONE, math.Min(LOBBY_MAX_ZONE, r.ZoneSpeed))
+
+	weapons := make([]string, 0, len(r.Weapons))
+	for _, name := range weaponNames {
+		for _, w := range r.Weapons {
+			if w == name {
+				weapons = append(weapons, name)
+				break
+			}
+		}
+	}
+	if len(weapons) == 0 {
+		weapons = append(weapons, weaponNames...)
+	}
+	r.Weapons = weapons
+	return r
+}
+
+// startingWeapon is what humans spawn with: the default weapon if the rules
+// allow it, otherwise the first allowed one.
+func (r MatchRules) startingWeapon() string {
+	for _, w := range r.Weapons {
+		if w == defaultWeapon {
+			return w
+		}
+	}
+	return r.Weapons[0]
+}
+
+func generateLobbyCode() string {
+	b := make([]byte, LOBBY_CODE_LENGTH)
+	rand.Read(b)
+	for i := range b {
+		b[i] = LOBBY_CODE_ALPHABET[int(b[i])%len(LOBBY_CODE_ALPHABET)]
+	}
+	return string(b)
+}
+
+// handleCreateLobby opens a private room and returns its invite code.
+func (rm *RoomManager) handleCreateLobby(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rm.mu.Lock()
+	if len(rm.lobbies) >= LOBBY_MAX_OPEN {
+		rm.mu.Unlock()
+		log.Printf("[LOBBY] Refusing to create a lobby, %d already open", LOBBY_MAX_OPEN)
+		http.Error(w, "too many open lobbies", http.StatusServiceUnavailable)
+		return
+	}
+	code := generateLobbyCode()
+	for rm.lobbies[code] != nil {
+		code = generateLobbyCode()
+	}
+	room := rm.createRoom(defaultRules(MATCH_SIZE), &Lobby{
+		Code:   code,
+		kicked: make(map[string]bool),
+	})
+	rm.lobbies[code] = room
+	rm.mu.Unlock()
+
+	log.Printf("[LOBBY %s] Created in %s", code, room.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"code":   code,
+		"roomId": room.ID,
+	})
+}
+
+// admitToLobby finds the room behind an invite code. Returning players get
+// back in at any time; anyone else only while the lobby is waiting and has
+// space. On refusal the close code and reason are returned instead.
+// Callers must hold rm.mu.
+func (rm *RoomManager) admitToLobby(code, sessionID string) (*Room, int, string) {
+	room := rm.lobbies[strings.ToUpper(code)]
+	if room == nil {
+		return nil, CLOSE_UNKNOWN_LOBBY, "unknown lobby code"
+	}
+
+	gs := room.server
+	if sessionID != "" && gs.hasSession(sessionID) {
+		return room, 0, ""
+	}
+
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	if sessionID != "" && gs.lobby.kicked[sessionID] {
+		return nil, CLOSE_KICKED, "kicked from lobby"
+	}
+	if gs.gameState.Phase != PHASE_LOBBY {
+		return nil, CLOSE_LOBBY_CLOSED, "match already started"
+	}
+	if gs.occupancy() >= ROOM_MAX_PLAYERS {
+		return nil, CLOSE_LOBBY_CLOSED, "lobby is full"
+	}
+	return room, 0, ""
+}
+
+// queueLobbyUpdate tells everyone in the lobby who is in it and what the
+// rules are. Callers must hold gs.mu.
+func (gs *GameServer) queueLobbyUpdate() {
+	players := make([]string, 0, len(gs.clients))
+	for _, client := range gs.clients {
+		players = append(players, client.player.ID)
+	}
+
+	gs.pendingEvents = append(gs.pendingEvents, &LobbyMessage{
+		Type:             "lobby",
+		Code:             gs.lobby.Code,
+		Host:             gs.lobby.HostID,
+		Rules:            gs.rules,
+		AvailableWeapons: weaponNames,
+		Players:          players,
+	})
+}
+
+// joinLobby makes the first player in the host. Callers must hold gs.mu.
+func (gs *GameServer) joinLobby(playerID string) {
+	gs.lobby.joined = true
+	if gs.lobby.HostID == "" {
+		gs.lobby.HostID = playerID
+		log.Printf("[LOBBY %s] %s is the host", gs.lobby.Code, playerID)
+	}
+	gs.queueLobbyUpdate()
+}
+
+// leaveLobby hands the host role to another player if the host left.
+// Callers must hold gs.mu.
+func (gs *GameServer) leaveLobby(playerID string) {
+	if gs.lobby.HostID == playerID {
+		gs.lobby.HostID = ""
+		for _, client := range gs.clients {
+			gs.lobby.HostID = client.player.ID
+			log.Printf("[LOBBY %s] Host left, %s is the new host", gs.lobby.Code, client.player.ID)
+			break
+		}
+	}
+	gs.queueLobbyUpdate()
+}
+
+// handleLobbyMessage applies a host command. Anything from a player who is
+// not the host, or sent outside a lobby, is ignored. Callers must hold gs.mu.
+func (gs *GameServer) handleLobbyMessage(player *Player, msg *InputMessage) {
+	if gs.lobby == nil || gs.lobby.HostID != player.ID {
+		return
+	}
+
+	switch msg.Type {
+	case "lobbyRules":
+		if gs.gameState.Phase != PHASE_LOBBY || msg.Rules == nil {
+			return
+		}
+		gs.applyRules(msg.Rules.validate())
+		log.Printf("[LOBBY %s] Rules set to %d players, zone speed %.2f, weapons %v",
+			gs.lobby.Code, gs.rules.Population, gs.rules.ZoneSpeed, gs.rules.Weapons)
+		gs.queueLobbyUpdate()
+	case "startMatch":
+		if gs.gameState.Phase == PHASE_LOBBY {
+			gs.lobby.startRequested = true
+		}
+	case "kick":
+		if msg.PlayerID == player.ID {
+			return
+		}
+		for _, client := range gs.clients {
+			if client.player.ID != msg.PlayerID {
+				continue
+			}
+			gs.lobby.kicked[client.sessionID] = true
+			gs.sessionMu.Lock()
+			delete(gs.sessions, client.sessionID)
+			gs.sessionMu.Unlock()
+			client.close(CLOSE_KICKED, "kicked by host")
+			log.Printf("[LOBBY %s] %s kicked %s", gs.lobby.Code, player.ID, msg.PlayerID)
+			break
+		}
+	}
+}
+
+// applyRules switches the room to new rules, re-equipping everyone and
+// reseeding the weapon pickups to match. The bot count follows on the next
+// tick. Callers must hold gs.mu.
+func (gs *GameServer) applyRules(rules MatchRules) {
+	gs.rules = rules
+	gs.respawnBots()
+	for _, p := range gs.gameState.Players {
+		if !p.IsBot {
+			resetInventory(p, rules.startingWeapon())
+		}
+	}
+
+	gs.gameState.WeaponPickups = make(map[string]*WeaponPickup)
+	gs.topUpPickups(0, 75, 0, 0)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// LoopMetrics describes how well the simulation keeps up with TICK_RATE.
+type LoopMetrics struct {
+	Ticks        int64   `json:"ticks"`
+	CatchUpTicks int64   `json:"catchUpTicks"`
+	SkippedTicks int64   `json:"skippedTicks"`
+	Overruns     int64   `json:"overruns"`
+	LastTickMs   float64 `json:"lastTickMs"`
+	AvgTickMs    float64 `json:"avgTickMs"`
+	MaxTickMs    float64 `json:"maxTickMs"`
+	Broadcasts   int64   `json:"broadcasts"`
+}
+
+type loopMetrics struct {
+	mu      sync.Mutex
+	current LoopMetrics
+	window  LoopMetrics
+}
+
+func (m *loopMetrics) recordTick(duration, budget time.Duration, catchUp bool) {
+	ms := float64(duration.Microseconds()) / 1000
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, stats := range []*LoopMetrics{&m.current, &m.window} {
+		stats.Ticks++
+		if catchUp {
+			stats.CatchUpTicks++
+		}
+		if duration > budget {
+			stats.Overruns++
+		}
+		stats.LastTickMs = ms
+		if ms > stats.MaxTickMs {
+			stats.MaxTickMs = ms
+		}
+		if stats.Ticks == 1 {
+			stats.AvgTickMs = ms
+		} else {
+			stats.AvgTickMs = stats.AvgTickMs*0.95 + ms*0.05
+		}
+	}
+}
+
+func (m *loopMetrics) recordSkipped(ticks int) {
+	m.mu.Lock()
+	m.current.SkippedTicks += int64(ticks)
+	m.window.SkippedTicks += int64(ticks)
+	m.mu.Unlock()
+}
+
+func (m *loopMetrics) recordBroadcast() {
+	m.mu.Lock()
+	m.current.Broadcasts++
+	m.window.Broadcasts++
+	m.mu.Unlock()
+}
+
+func (m *loopMetrics) snapshot() LoopMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+// logWindow reports the last interval if the server fell behind in it, then
+// starts a new interval.
+func (m *loopMetrics) logWindow() {
+	m.mu.Lock()
+	window := m.window
+	m.window = LoopMetrics{}
+	m.mu.Unlock()
+
+	if window.Overruns > 0 || window.SkippedTicks > 0 || window.CatchUpTicks > 0 {
+		log.Printf("[LOOP] Overloaded: %d ticks, %d overruns, %d catch-up, %d skipped, avg %.2fms, max %.2fms",
+			window.Ticks, window.Overruns, window.CatchUpTicks, window.SkippedTicks, window.AvgTickMs, window.MaxTickMs)
+	}
+}
+
+// startGameLoop runs the simulation on a fixed timestep. When a tick runs late
+// the loop runs the missed ticks back to back, up to MAX_CATCHUP_TICKS, and
+// drops the rest. State is broadcast after simulation, never in between ticks.
+func (gs *GameServer) startGameLoop() {
+	interval := time.Second / TICK_RATE
+	ticksPerBroadcast := TICK_RATE / BROADCAST_RATE
+	if ticksPerBroadcast < 1 {
+		ticksPerBroadcast = 1
+	}
+
+	tick := 0
+	lastBroadcastTick := 0
+	next := time.Now()
+	for {
+		select {
+		case <-gs.stop:
+			return
+		default:
+		}
+
+		now := time.Now()
+		if now.Before(next) {
+			time.Sleep(next.Sub(now))
+			continue
+		}
+
+		due := int(now.Sub(next)/interval) + 1
+		if due > MAX_CATCHUP_TICKS {
+			skipped := due - MAX_CATCHUP_TICKS
+			gs.metrics.recordSkipped(skipped)
+			next = next.Add(time.Duration(skipped) * interval)
+			due = MAX_CATCHUP_TICKS
+		}
+
+		for i := 0; i < due; i++ {
+			start := time.Now()
+			gs.updateGame(tick)
+			gs.metrics.recordTick(time.Since(start), interval, i > 0)
+			tick++
+			next = next.Add(interval)
+
+			if tick%METRICS_LOG_INTERVAL == 0 {
+				gs.metrics.logWindow()
+			}
+		}
+
+		if tick-lastBroadcastTick >= ticksPerBroadcast {
+			lastBroadcastTick = tick
+			gs.broadcastState()
+			gs.metrics.recordBroadcast()
+		}
+	}
+}
+
+// shutdown stops the game loop and disconnects everyone still in the room.
+func (gs *GameServer) shutdown() {
+	close(gs.stop)
+
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	for _, client := range gs.clients {
+		client.close(websocket.CloseGoingAway, "room closed")
+	}
+}
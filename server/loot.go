@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+// dropLoot scatters the victim's weapons, reserve ammo, consumables and armor as
+// pickups around the death position and empties their inventory.
+func (gs *GameServer) dropLoot(player *Player) {
+	syncActiveSlot(player)
+	cancelReload(player)
+	cancelHeal(player)
+
+	weapons := make([]InventorySlot, 0, INVENTORY_WEAPON_SLOTS)
+	items := make(map[string]int)
+	if player.Inventory != nil {
+		for _, slot := range player.Inventory.Slots {
+			if slot.Weapon != "" {
+				weapons = append(weapons, slot)
+			}
+		}
+		for item, amount := range player.Inventory.Consumables {
+			if amount > 0 {
+				items[item] = amount
+			}
+		}
+	}
+
+	count := len(weapons) + len(items)
+	if player.Ammo > 0 {
+		count++
+	}
+	if player.VestTier > 0 {
+		count++
+	}
+	if player.HelmetTier > 0 {
+		count++
+	}
+	if count == 0 {
+		return
+	}
+
+	i := 0
+	nextPosition := func() (float64, float64) {
+		angle := float64(i) * 2 * math.Pi / float64(count)
+		i++
+		return player.X + math.Cos(angle)*LOOT_SCATTER_RADIUS, player.Y + math.Sin(angle)*LOOT_SCATTER_RADIUS
+	}
+
+	for _, slot := range weapons {
+		x, y := nextPosition()
+		gs.dropWeapon(x, y, slot, "")
+	}
+
+	if player.Ammo > 0 {
+		x, y := nextPosition()
+		ammoID := fmt.Sprintf("ammo_%d", gs.nextAmmoID)
+		gs.nextAmmoID++
+		gs.gameState.AmmoPickups[ammoID] = &AmmoPickup{
+			ID:     ammoID,
+			X:      roundFloat(x, 2),
+			Y:      roundFloat(y, 2),
+			Amount: player.Ammo,
+			Active: true,
+		}
+	}
+
+	for item, amount := range items {
+		x, y := nextPosition()
+		gs.spawnConsumablePickup(x, y, item, amount, "")
+	}
+
+	if player.VestTier > 0 {
+		x, y := nextPosition()
+		gs.spawnArmorPickup(x, y, ARMOR_VEST, player.VestTier, player.VestDurability)
+	}
+	if player.HelmetTier > 0 {
+		x, y := nextPosition()
+		gs.spawnArmorPickup(x, y, ARMOR_HELMET, player.HelmetTier, player.HelmetDurability)
+	}
+
+	log.Printf("[LOOT] Player %s dropped %d weapons and %d ammo at (%.2f, %.2f)", player.ID, len(weapons), player.Ammo, player.X, player.Y)
+
+	player.Inventory = newInventory("")
+	player.Weapon = ""
+	player.Magazine = 0
+	player.Ammo = 0
+	player.Boost = 0
+	resetArmor(player)
+}
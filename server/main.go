@@ -9,14 +9,16 @@ import (
 	"net/http"
 	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-func NewGameServer() *GameServer {
+func NewGameServer(roomID string, rules MatchRules) *GameServer {
 	gs := &GameServer{
+		roomID:  roomID,
+		rules:   rules,
+		stop:    make(chan struct{}),
 		clients: make(map[*websocket.Conn]*clientConn),
 		gameState: &GameState{
 			Players:       make(map[string]*Player),
@@ -24,128 +26,53 @@ func NewGameServer() *GameServer {
 			AmmoPickups:   make(map[string]*AmmoPickup),
 			WeaponPickups: make(map[string]*WeaponPickup),
 			HealthPickups: make(map[string]*HealthPickup),
+			ArmorPickups:  make(map[string]*ArmorPickup),
 			Buildings:     []Building{},
 			Trees:         []Tree{},
-			ZoneCenter:    0,
 			ZoneRadius:    ZONE_INITIAL_SIZE,
 			GameTime:      0,
-			Phase:         "lobby",
+			Phase:         PHASE_LOBBY,
+			MatchID:       1,
 		},
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true
-			},
-		},
-		nextBulletID:    1,
-		nextAmmoID:      1,
-		nextHealthID:    1,
-		sessions:        make(map[string]*Player),
-		botStates:       make(map[string]*BotState),
-		generatedChunks: make(map[string]bool),
-		chunkData:       make(map[string]*WorldChunk),
-		pendingChunks:   make([]struct{ X, Y float64 }, 0),
-		inputQueue:      make(map[string][]QueuedInput),
-		currentTick:     0,
-		zoneDamageAccum: make(map[string]float64),
+		nextBulletID:     1,
+		nextBotID:        1,
+		nextAmmoID:       1,
+		nextHealthID:     1,
+		nextArmorID:      1,
+		sessions:         make(map[string]*Player),
+		botStates:        make(map[string]*BotState),
+		generatedChunks:  make(map[string]bool),
+		chunkData:        make(map[string]*WorldChunk),
+		pendingChunks:    make([]struct{ X, Y float64 }, 0),
+		inputQueue:       make(map[string][]QueuedInput),
+		shotQueue:        make(map[string][]QueuedInput),
+		lastInputID:      make(map[string]int),
+		processedInputID: make(map[string]int),
+		inputRates:       make(map[string]*InputRate),
+		currentTick:      0,
+		zoneDamageAccum:  make(map[string]float64),
+		positionHistory:  make(map[string]*PositionHistory),
 		// Initialize spatial grids for fast collision detection
 		buildingGrid: NewSpatialGrid(SPATIAL_GRID_CELL_SIZE),
 		treeGrid:     NewSpatialGrid(SPATIAL_GRID_CELL_SIZE),
 	}
 
-	rand.Seed(42)
-
 	gs.generateChunk(0, 0)
 	gs.generateChunk(-CHUNK_SIZE, 0)
 	gs.generateChunk(CHUNK_SIZE, 0)
 	gs.generateChunk(0, -CHUNK_SIZE)
 	gs.generateChunk(0, CHUNK_SIZE)
 
-	rand.Seed(time.Now().UnixNano())
-
-	for i := 0; i < 120; i++ {
-		ammoID := fmt.Sprintf("ammo_%d", gs.nextAmmoID)
-		gs.nextAmmoID++
-		x, y := gs.findValidPickupPosition(10, true)
-		ammoPickup := &AmmoPickup{
-			ID:     ammoID,
-			X:      x,
-			Y:      y,
-			Amount: 75,
-			Active: true,
-		}
-		gs.gameState.AmmoPickups[ammoID] = ammoPickup
-	}
-
-	weapons := []string{"pistol", "rifle", "machinegun", "shotgun"}
-	for i := 0; i < 75; i++ {
-		weaponID := fmt.Sprintf("weapon_%d", gs.nextAmmoID)
-		gs.nextAmmoID++
-		x, y := gs.findValidPickupPosition(10, true)
-		weaponPickup := &WeaponPickup{
-			ID:     weaponID,
-			X:      x,
-			Y:      y,
-			Weapon: weapons[rand.Intn(len(weapons))],
-			Active: true,
-		}
-		gs.gameState.WeaponPickups[weaponID] = weaponPickup
-	}
-
-	for i := 0; i < 40; i++ {
-		healthID := fmt.Sprintf("health_%d", gs.nextHealthID)
-		gs.nextHealthID++
-		x, y := gs.findValidPickupPosition(12, true)
-		healthPickup := &HealthPickup{
-			ID:     healthID,
-			X:      x,
-			Y:      y,
-			Amount: 75,
-			Active: true,
-		}
-		gs.gameState.HealthPickups[healthID] = healthPickup
-	}
-
-	for i := 0; i < 5; i++ {
-		enemyID := fmt.Sprintf("enemy_%d", i+1)
-		enemy := &Player{
-			ID:        enemyID,
-			Angle:     0,
-			Health:    1000,
-			Alive:     true,
-			Velocity:  16.67,
-			Ammo:      100,
-			Weapon:    "pistol",
-			Score:     0,
-			Kills:     0,
-			LastShoot: 0,
-		}
-
-		var ok bool
-		enemy.X, enemy.Y, ok = gs.findValidPosition(0, 0, PLAYER_RADIUS, 100, true)
-		if !ok {
-			log.Printf("Warning: Could not find valid spawn position for bot %s", enemyID)
-		}
-
-		gs.gameState.Players[enemyID] = enemy
-		gs.botStates[enemyID] = &BotState{
-			TargetX:       enemy.X,
-			TargetY:       enemy.Y,
-			LastDirChange: 0,
-			MoveAngle:     rand.Float64() * 2 * math.Pi,
-		}
-	}
+	gs.resetZone()
+	gs.topUpPickups(120, 75, 40, 30)
 
 	return gs
 }
 
-func (gs *GameServer) handleConnection(w http.ResponseWriter, r *http.Request) {
-	conn, err := gs.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Println("Upgrade error:", err)
-		return
-	}
-
-	sessionID := r.URL.Query().Get("session")
+// acceptClient adds a connection that completed the handshake to this room,
+// restoring the player from sessionID when the session is known. The caller
+// then runs handleClient to read from the connection.
+func (gs *GameServer) acceptClient(conn *websocket.Conn, sessionID string, caps Capabilities) *Player {
 	var player *Player
 	var playerID string
 
@@ -165,10 +92,19 @@ func (gs *GameServer) handleConnection(w http.ResponseWriter, r *http.Request) {
 				Alive:     savedPlayer.Alive,
 				Velocity:  savedPlayer.Velocity,
 				Ammo:      savedPlayer.Ammo,
+				Magazine:  savedPlayer.Magazine,
+				Inventory: savedPlayer.Inventory.copy(),
 				Weapon:    savedPlayer.Weapon,
 				Score:     savedPlayer.Score,
 				Kills:     savedPlayer.Kills,
 				LastShoot: 0,
+
+				VestTier:         savedPlayer.VestTier,
+				VestDurability:   savedPlayer.VestDurability,
+				HelmetTier:       savedPlayer.HelmetTier,
+				HelmetDurability: savedPlayer.HelmetDurability,
+
+				Boost: savedPlayer.Boost,
 			}
 			log.Printf("Restoring session %s for player %s at (%.2f, %.2f) with %d ammo",
 				sessionID, playerID, player.X, player.Y, player.Ammo)
@@ -189,15 +125,15 @@ func (gs *GameServer) handleConnection(w http.ResponseWriter, r *http.Request) {
 			X:         spawnX,
 			Y:         spawnY,
 			Angle:     0,
-			Health:    1000,
+			Health:    MAX_HEALTH,
 			Alive:     true,
 			Velocity:  100.0,
 			Ammo:      100,
-			Weapon:    "pistol",
 			Score:     0,
 			Kills:     0,
 			LastShoot: 0,
 		}
+		resetInventory(player, gs.rules.startingWeapon())
 
 		if sessionID == "" {
 			sessionID = fmt.Sprintf("session_%d", time.Now().UnixNano())
@@ -214,52 +150,40 @@ func (gs *GameServer) handleConnection(w http.ResponseWriter, r *http.Request) {
 			log.Printf("Player %s already connected, closing old connection", playerID)
 			delete(gs.clients, existingConn)
 			delete(gs.gameState.Players, playerID)
-			existingConn.Close()
+			existingClient.close(websocket.ClosePolicyViolation, "connected from another session")
 		}
 	}
 
-	clientConn := &clientConn{
-		conn:        conn,
-		player:      player,
-		knownChunks: make(map[string]bool),
-		lastState:   nil,
-	}
+	clientConn := newClientConn(conn, player, caps)
+	clientConn.sessionID = sessionID
 
 	gs.clients[conn] = clientConn
 	gs.gameState.Players[playerID] = player
+	if gs.lobby != nil {
+		gs.joinLobby(playerID)
+	}
 	gs.mu.Unlock()
 
-	log.Printf("Player %s connected at (%.2f, %.2f)", playerID, player.X, player.Y)
-
-	go func() {
-		time.Sleep(10 * time.Millisecond)
-
-		gs.sendChunksToClient(clientConn, player.X, player.Y)
-
-		initDiff := gs.createStateDiff(clientConn)
-		initDiff.Type = "init"
+	// Sequence numbers restart with every connection.
+	gs.inputQueueMu.Lock()
+	delete(gs.inputQueue, playerID)
+	delete(gs.shotQueue, playerID)
+	delete(gs.lastInputID, playerID)
+	delete(gs.processedInputID, playerID)
+	gs.inputQueueMu.Unlock()
 
-		initMsg := map[string]interface{}{
-			"type":      "init",
-			"playerId":  playerID,
-			"sessionId": sessionID,
-			"state":     initDiff,
-		}
+	log.Printf("Player %s connected to %s at (%.2f, %.2f) using protocol v%d, %s encoding, compression %t, features %v",
+		playerID, gs.roomID, player.X, player.Y, caps.ProtocolVersion, caps.Encoding, caps.Compression, caps.Features)
 
-		finalState, err := json.Marshal(initMsg)
-		if err == nil {
-			clientConn.writeMu.Lock()
-			err = conn.WriteMessage(websocket.TextMessage, finalState)
-			clientConn.writeMu.Unlock()
-			if err != nil {
-				log.Printf("Error sending initial state: %v", err)
-			} else {
-				log.Printf("Sent initial state to player %s", playerID)
-			}
-		}
-	}()
+	go gs.writeLoop(clientConn, sessionID)
+	return player
+}
 
-	go gs.handleClient(conn, player)
+func (gs *GameServer) hasSession(sessionID string) bool {
+	gs.sessionMu.RLock()
+	defer gs.sessionMu.RUnlock()
+	_, exists := gs.sessions[sessionID]
+	return exists
 }
 
 func (gs *GameServer) savePlayerState(playerID string, player *Player) {
@@ -274,6 +198,13 @@ func (gs *GameServer) savePlayerState(playerID string, player *Player) {
 			savedPlayer.Health = player.Health
 			savedPlayer.Alive = player.Alive
 			savedPlayer.Ammo = player.Ammo
+			savedPlayer.Magazine = player.Magazine
+			savedPlayer.Inventory = snapshotInventory(player)
+			savedPlayer.VestTier = player.VestTier
+			savedPlayer.VestDurability = player.VestDurability
+			savedPlayer.HelmetTier = player.HelmetTier
+			savedPlayer.HelmetDurability = player.HelmetDurability
+			savedPlayer.Boost = player.Boost
 			savedPlayer.Weapon = player.Weapon
 			savedPlayer.Score = player.Score
 			savedPlayer.Kills = player.Kills
@@ -291,8 +222,7 @@ func (gs *GameServer) handleClient(conn *websocket.Conn, player *Player) {
 	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 
 	for {
-		var msg InputMessage
-		err := conn.ReadJSON(&msg)
+		messageType, data, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error for player %s: %v", player.ID, err)
@@ -300,74 +230,157 @@ func (gs *GameServer) handleClient(conn *websocket.Conn, player *Player) {
 			return
 		}
 
-		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		gs.handleMessage(conn, player, messageType, data)
+	}
+}
 
-		if msg.Type == "ping" {
-			gs.mu.RLock()
-			clientConn, exists := gs.clients[conn]
-			gs.mu.RUnlock()
-			if exists {
-				pongMsg := map[string]interface{}{
-					"type": "pong",
-					"time": msg.Time,
-				}
-				clientConn.writeMu.Lock()
-				conn.WriteJSON(pongMsg)
-				clientConn.writeMu.Unlock()
-			}
-			continue
+// handleMessage applies one message from a connected player.
+func (gs *GameServer) handleMessage(conn *websocket.Conn, player *Player, messageType int, data []byte) {
+	var msg InputMessage
+	if err := readInputMessage(messageType, data, &msg); err != nil {
+		log.Printf("Invalid message from player %s: %v", player.ID, err)
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+
+	if msg.Type == "ping" {
+		gs.mu.RLock()
+		clientConn, exists := gs.clients[conn]
+		gs.mu.RUnlock()
+		if exists {
+			clientConn.enqueueJSON(map[string]interface{}{
+				"type": "pong",
+				"time": msg.Time,
+			})
 		}
+		return
+	}
 
-		gs.mu.Lock()
-		gamePlayer := gs.gameState.Players[player.ID]
-		if gamePlayer == nil {
+	gs.mu.Lock()
+	tick := gs.currentTick
+	gamePlayer := gs.gameState.Players[player.ID]
+	if gamePlayer == nil {
+		gs.mu.Unlock()
+		return
+	}
+
+	if msg.Type == "respawn" {
+		if gs.gameState.Phase == PHASE_PLAYING || gs.gameState.Phase == PHASE_FINISHED {
 			gs.mu.Unlock()
-			continue
+			return
+		}
+		gamePlayer.Health = MAX_HEALTH
+		gamePlayer.Boost = 0
+		gamePlayer.Alive = true
+		gamePlayer.Ammo = 100
+		resetInventory(gamePlayer, gs.rules.startingWeapon())
+		resetArmor(gamePlayer)
+		var ok bool
+		gamePlayer.X, gamePlayer.Y, ok = gs.findValidPosition(0, 0, PLAYER_RADIUS, 100, true)
+		if !ok {
+			log.Printf("Warning: Could not find valid respawn position for player %s", gamePlayer.ID)
+		}
+		gamePlayer.Angle = 0
+		gs.savePlayerState(gamePlayer.ID, gamePlayer)
+		log.Printf("Player %s respawned at (%.2f, %.2f)", gamePlayer.ID, gamePlayer.X, gamePlayer.Y)
+		gs.mu.Unlock()
+	} else if msg.Type == "reload" {
+		if gamePlayer.Alive {
+			gs.startReload(gamePlayer)
+		}
+		gs.mu.Unlock()
+	} else if msg.Type == "switchWeapon" {
+		if gamePlayer.Alive {
+			gs.switchWeapon(gamePlayer, msg.Slot)
 		}
+		gs.mu.Unlock()
+	} else if msg.Type == "dropItem" {
+		if gamePlayer.Alive {
+			gs.dropItem(gamePlayer, msg.Item, msg.Slot)
+		}
+		gs.mu.Unlock()
+	} else if msg.Type == "useItem" {
+		if gamePlayer.Alive {
+			gs.useItem(gamePlayer, msg.Item)
+		}
+		gs.mu.Unlock()
+	} else if msg.Type == "lobbyRules" || msg.Type == "startMatch" || msg.Type == "kick" {
+		gs.handleLobbyMessage(gamePlayer, &msg)
+		gs.mu.Unlock()
+	} else if msg.Type == "input" {
+		if msg.Shoot {
+			gs.mu.Unlock()
 
-		if msg.Type == "respawn" {
-			gamePlayer.Health = 1000
-			gamePlayer.Alive = true
-			gamePlayer.Ammo = 100
-			gamePlayer.Weapon = "pistol"
-			var ok bool
-			gamePlayer.X, gamePlayer.Y, ok = gs.findValidPosition(0, 0, PLAYER_RADIUS, 100, true)
-			if !ok {
-				log.Printf("Warning: Could not find valid respawn position for player %s", gamePlayer.ID)
+			// Shots have their own queue so a backlog of moves never drops
+			// them. The client sends at most one per cooldown, so the cap
+			// only bites on floods.
+			gs.inputQueueMu.Lock()
+			if len(gs.shotQueue[player.ID]) < MAX_QUEUED_SHOTS {
+				gs.shotQueue[player.ID] = append(gs.shotQueue[player.ID], QueuedInput{
+					PlayerID:   player.ID,
+					Shoot:      true,
+					Angle:      msg.Angle,
+					Tick:       tick + 1,
+					ClientTick: msg.Tick,
+				})
 			}
-			gamePlayer.Angle = 0
-			gs.savePlayerState(gamePlayer.ID, gamePlayer)
-			log.Printf("Player %s respawned at (%.2f, %.2f)", gamePlayer.ID, gamePlayer.X, gamePlayer.Y)
-			gs.mu.Unlock()
-		} else if msg.Type == "input" {
-			if msg.Shoot {
+			gs.inputQueueMu.Unlock()
+		} else {
+			if msg.Angle != 0 {
 				gamePlayer.Angle = roundFloat(msg.Angle, 4)
-				gs.createBullet(gamePlayer)
-				gs.savePlayerState(gamePlayer.ID, gamePlayer)
-				gs.mu.Unlock()
-			} else {
-				if msg.Angle != 0 {
-					gamePlayer.Angle = roundFloat(msg.Angle, 4)
+			}
+			gs.mu.Unlock()
+
+			gs.inputQueueMu.Lock()
+			if msg.InputID > 0 {
+				if msg.InputID <= gs.lastInputID[player.ID] {
+					gs.inputQueueMu.Unlock()
+					return
 				}
-				gs.mu.Unlock()
+				gs.lastInputID[player.ID] = msg.InputID
+			}
 
-				if msg.MoveX != 0 || msg.MoveY != 0 {
-					gs.inputQueueMu.Lock()
-					gs.inputQueue[player.ID] = append(gs.inputQueue[player.ID], QueuedInput{
+			if msg.MoveX != 0 || msg.MoveY != 0 {
+				if !gs.allowMovementInput(player.ID, tick) {
+					gs.inputQueueMu.Unlock()
+					return
+				}
+				gs.inputQueue[player.ID] = append(gs.inputQueue[player.ID], QueuedInput{
+					PlayerID: player.ID,
+					InputID:  msg.InputID,
+					MoveX:    msg.MoveX,
+					MoveY:    msg.MoveY,
+					Angle:    msg.Angle,
+					Tick:     tick + 1,
+					ClientX:  msg.ClientX,
+					ClientY:  msg.ClientY,
+				})
+			} else if msg.InputID > 0 {
+				// A stop input has nothing to simulate. With nothing queued it
+				// is acked at once; otherwise it is queued as a no-op so its id
+				// is acked in order with the moves before it. Stops are never
+				// dropped, and back-to-back stops share one entry, so the queue
+				// still stays bounded.
+				queue := gs.inputQueue[player.ID]
+				if len(queue) == 0 {
+					gs.processedInputID[player.ID] = msg.InputID
+				} else if last := &queue[len(queue)-1]; last.MoveX == 0 && last.MoveY == 0 {
+					last.InputID = msg.InputID
+					last.Angle = msg.Angle
+				} else {
+					gs.inputQueue[player.ID] = append(queue, QueuedInput{
 						PlayerID: player.ID,
-						MoveX:    msg.MoveX,
-						MoveY:    msg.MoveY,
+						InputID:  msg.InputID,
 						Angle:    msg.Angle,
-						Tick:     gs.currentTick + 1,
-						ClientX:  msg.ClientX,
-						ClientY:  msg.ClientY,
+						Tick:     tick + 1,
 					})
-					gs.inputQueueMu.Unlock()
 				}
 			}
-		} else {
-			gs.mu.Unlock()
+			gs.inputQueueMu.Unlock()
 		}
+	} else {
+		gs.mu.Unlock()
 	}
 }
 
@@ -375,6 +388,9 @@ func (gs *GameServer) processQueuedInputs(tick int) {
 	gs.inputQueueMu.Lock()
 	defer gs.inputQueueMu.Unlock()
 
+	gs.queueBotInputs()
+	gs.fireQueuedShots(tick)
+
 	for playerID, inputs := range gs.inputQueue {
 		if len(inputs) == 0 {
 			continue
@@ -394,12 +410,18 @@ func (gs *GameServer) processQueuedInputs(tick int) {
 				break
 			}
 
+			if input.InputID > 0 {
+				gs.processedInputID[playerID] = input.InputID
+			}
+
 			if !validateInput(input.MoveX, input.MoveY, input.Angle) {
 				processed++
 				continue
 			}
 
 			if input.MoveX != 0 || input.MoveY != 0 {
+				interruptHeal(player, "moved")
+
 				len := math.Sqrt(input.MoveX*input.MoveX + input.MoveY*input.MoveY)
 				if len > 1.0 {
 					input.MoveX /= len
@@ -463,6 +485,16 @@ func (gs *GameServer) processQueuedInputs(tick int) {
 					newY = roundFloat(input.ClientY, 2)
 				}
 
+				// The client position is trusted within a tolerance, but never
+				// further than the player could move in one tick.
+				stepX := newX - player.X
+				stepY := newY - player.Y
+				if stepSq := stepX*stepX + stepY*stepY; stepSq > moveSpeed*moveSpeed {
+					scale := moveSpeed / math.Sqrt(stepSq)
+					newX = roundFloat(player.X+stepX*scale, 2)
+					newY = roundFloat(player.Y+stepY*scale, 2)
+				}
+
 				canMoveX := true
 				canMoveY := true
 
@@ -536,11 +568,16 @@ func (gs *GameServer) processQueuedInputs(tick int) {
 			}
 
 			processed++
+			// At most one movement input is simulated per player per tick;
+			// the rest wait in the queue.
+			break
 		}
 
 		if processed > 0 {
 			gs.inputQueue[playerID] = gs.inputQueue[playerID][processed:]
-			gs.savePlayerState(playerID, player)
+			if !player.IsBot {
+				gs.savePlayerState(playerID, player)
+			}
 		}
 
 		gs.mu.Unlock()
@@ -558,14 +595,48 @@ func (gs *GameServer) processQueuedInputs(tick int) {
 	}
 }
 
+// fireQueuedShots fires every shot that is due. Callers must hold
+// inputQueueMu.
+func (gs *GameServer) fireQueuedShots(tick int) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	for playerID, shots := range gs.shotQueue {
+		player := gs.gameState.Players[playerID]
+		if player == nil {
+			delete(gs.shotQueue, playerID)
+			continue
+		}
+
+		fired := 0
+		for _, shot := range shots {
+			if shot.Tick > tick {
+				break
+			}
+			if player.Alive && validateInput(0, 0, shot.Angle) {
+				player.Angle = roundFloat(shot.Angle, 4)
+				interruptHeal(player, "shot")
+				gs.createBullet(player, gs.rewindTicks(shot.ClientTick))
+			}
+			fired++
+		}
+		if fired == len(shots) {
+			delete(gs.shotQueue, playerID)
+		} else {
+			gs.shotQueue[playerID] = shots[fired:]
+		}
+	}
+}
+
 func (gs *GameServer) disconnectClient(conn *websocket.Conn, playerID string) {
 	gs.mu.Lock()
-	_, exists := gs.clients[conn]
+	client, exists := gs.clients[conn]
 	if !exists {
 		gs.mu.Unlock()
 		return
 	}
 	delete(gs.clients, conn)
+	client.close(websocket.CloseNormalClosure, "")
 
 	hasOtherConnection := false
 	for otherConn, otherClient := range gs.clients {
@@ -577,12 +648,19 @@ func (gs *GameServer) disconnectClient(conn *websocket.Conn, playerID string) {
 
 	if !hasOtherConnection {
 		delete(gs.gameState.Players, playerID)
+		if gs.lobby != nil {
+			gs.leaveLobby(playerID)
+		}
 	}
 	gs.mu.Unlock()
 
 	gs.inputQueueMu.Lock()
 	if !hasOtherConnection {
 		delete(gs.inputQueue, playerID)
+		delete(gs.shotQueue, playerID)
+		delete(gs.lastInputID, playerID)
+		delete(gs.processedInputID, playerID)
+		delete(gs.inputRates, playerID)
 	}
 	gs.inputQueueMu.Unlock()
 
@@ -590,12 +668,16 @@ func (gs *GameServer) disconnectClient(conn *websocket.Conn, playerID string) {
 }
 
 func (gs *GameServer) updateGame(tick int) {
+	gs.mu.Lock()
 	gs.currentTick = tick
+	gs.mu.Unlock()
 	gs.processQueuedInputs(tick)
 
 	gs.mu.Lock()
 
 	gs.processPendingChunks(3)
+	gs.updateReloads(tick)
+	gs.updateConsumables(tick)
 
 	gs.gameState.GameTime = tick
 
@@ -604,49 +686,50 @@ func (gs *GameServer) updateGame(tick int) {
 			continue
 		}
 
-		bullet.X = roundFloat(bullet.X+math.Cos(bullet.Angle)*bullet.Speed, 2)
-		bullet.Y = roundFloat(bullet.Y+math.Sin(bullet.Angle)*bullet.Speed, 2)
-
-		if math.Abs(bullet.X) > 10000 || math.Abs(bullet.Y) > 10000 {
-			bullet.Active = false
-			continue
+		step := bullet.Speed
+		expires := false
+		if remaining := bullet.Range - bullet.Traveled; step >= remaining {
+			step = math.Max(0, remaining)
+			expires = true
 		}
 
-		hitObstacle := false
-		nearbyBuildings := gs.buildingGrid.GetNearby(bullet.X, bullet.Y, 100)
+		startX, startY := bullet.X, bullet.Y
+		endX := startX + math.Cos(bullet.Angle)*step
+		endY := startY + math.Sin(bullet.Angle)*step
+		midX, midY := (startX+endX)/2, (startY+endY)/2
+
+		// Sweep the whole segment travelled this tick and stop at the
+		// earliest thing it touches, so fast bullets can't skip over thin
+		// walls or graze past players between samples.
+		hitT := math.Inf(1)
+		hitTarget := ""
+		var hitPlayer *Player
+
+		nearbyBuildings := gs.buildingGrid.GetNearby(midX, midY, 100+step/2)
 		for _, entity := range nearbyBuildings {
 			building, ok := entity.(Building)
 			if !ok {
 				continue
 			}
-			if bullet.X >= building.X && bullet.X <= building.X+building.Width &&
-				bullet.Y >= building.Y && bullet.Y <= building.Y+building.Height {
-				bullet.Active = false
-				hitObstacle = true
-				break
+			t, ok := segmentHitsAABB(startX, startY, endX, endY,
+				building.X, building.Y, building.X+building.Width, building.Y+building.Height)
+			if ok && t < hitT {
+				hitT = t
+				hitTarget = "building"
 			}
 		}
 
-		if !hitObstacle {
-			nearbyTrees := gs.treeGrid.GetNearby(bullet.X, bullet.Y, 50)
-			for _, entity := range nearbyTrees {
-				tree, ok := entity.(Tree)
-				if !ok {
-					continue
-				}
-				dx := bullet.X - tree.X
-				dy := bullet.Y - tree.Y
-				dist := math.Sqrt(dx*dx + dy*dy)
-				if dist < tree.Size {
-					bullet.Active = false
-					hitObstacle = true
-					break
-				}
+		nearbyTrees := gs.treeGrid.GetNearby(midX, midY, 50+step/2)
+		for _, entity := range nearbyTrees {
+			tree, ok := entity.(Tree)
+			if !ok {
+				continue
+			}
+			t, ok := segmentHitsCircle(startX, startY, endX, endY, tree.X, tree.Y, tree.Size)
+			if ok && t < hitT {
+				hitT = t
+				hitTarget = "tree"
 			}
-		}
-
-		if hitObstacle {
-			continue
 		}
 
 		for _, player := range gs.gameState.Players {
@@ -654,25 +737,56 @@ func (gs *GameServer) updateGame(tick int) {
 				continue
 			}
 
-			dx := bullet.X - player.X
-			dy := bullet.Y - player.Y
-			dist := math.Sqrt(dx*dx + dy*dy)
-			if dist < BULLET_HIT_RADIUS {
-				player.Health -= 25
+			targetX, targetY, targetAlive := gs.targetPosition(player, bullet, tick)
+			if !targetAlive {
+				continue
+			}
+
+			t, ok := segmentHitsCircle(startX, startY, endX, endY, targetX, targetY, BULLET_HIT_RADIUS)
+			if ok && t < hitT {
+				hitT = t
+				hitTarget = "player"
+				hitPlayer = player
+			}
+		}
+
+		if hitTarget == "" {
+			bullet.X = roundFloat(endX, 2)
+			bullet.Y = roundFloat(endY, 2)
+			bullet.Traveled += step
+			if expires || math.Abs(bullet.X) > 10000 || math.Abs(bullet.Y) > 10000 {
 				bullet.Active = false
-				if player.Health <= 0 {
-					player.Health = 0
-					player.Alive = false
+			}
+			continue
+		}
 
-					if bullet.PlayerID != "" {
-						killer := gs.gameState.Players[bullet.PlayerID]
-						if killer != nil {
-							killer.Kills++
-							killer.Score += 100
-						}
+		bullet.X = roundFloat(startX+(endX-startX)*hitT, 2)
+		bullet.Y = roundFloat(startY+(endY-startY)*hitT, 2)
+		bullet.Traveled += step * hitT
+		bullet.Active = false
+
+		impact := BulletImpact{BulletID: bullet.ID, X: bullet.X, Y: bullet.Y, Target: hitTarget}
+		if hitPlayer != nil {
+			impact.PlayerID = hitPlayer.ID
+		}
+		gs.pendingImpacts = append(gs.pendingImpacts, impact)
+
+		if hitPlayer != nil {
+			player := hitPlayer
+			player.Health -= applyArmor(player, bullet.damageAt(bullet.Traveled))
+			interruptHeal(player, "hit")
+			if player.Health <= 0 {
+				player.Health = 0
+				player.Alive = false
+				gs.dropLoot(player)
+
+				if bullet.PlayerID != "" {
+					killer := gs.gameState.Players[bullet.PlayerID]
+					if killer != nil {
+						killer.Kills++
+						killer.Score += 100
 					}
 				}
-				break
 			}
 		}
 	}
@@ -712,16 +826,21 @@ func (gs *GameServer) updateGame(tick int) {
 			dx := player.X - weapon.X
 			dy := player.Y - weapon.Y
 			dist := math.Sqrt(dx*dx + dy*dy)
-			if dist < PICKUP_RADIUS {
-				oldWeapon := player.Weapon
-				oldAmmo := player.Ammo
-				player.Weapon = weapon.Weapon
-				weaponObj := GetWeapon(weapon.Weapon)
-				player.Ammo += weaponObj.GetInitialAmmo()
-				player.Score += 5
-				weapon.Active = false
-				log.Printf("[PICKUP] Player %s collected weapon %s at (%.2f, %.2f), weapon: %s -> %s, ammo: %d -> %d", player.ID, weapon.Weapon, weapon.X, weapon.Y, oldWeapon, player.Weapon, oldAmmo, player.Ammo)
+			if dist >= PICKUP_RADIUS {
+				// The dropper has walked off their item; it is fair game again.
+				if weapon.DroppedBy == player.ID {
+					weapon.DroppedBy = ""
+				}
+				continue
+			}
+			oldWeapon := player.Weapon
+			oldAmmo := player.Ammo
+			if !gs.pickUpWeapon(player, weapon) {
+				continue
 			}
+			player.Score += 5
+			weapon.Active = false
+			log.Printf("[PICKUP] Player %s collected weapon %s at (%.2f, %.2f), weapon: %s -> %s, ammo: %d -> %d", player.ID, weapon.Weapon, weapon.X, weapon.Y, oldWeapon, player.Weapon, oldAmmo, player.Ammo)
 		}
 
 		for _, health := range gs.gameState.HealthPickups {
@@ -731,12 +850,28 @@ func (gs *GameServer) updateGame(tick int) {
 			dx := player.X - health.X
 			dy := player.Y - health.Y
 			dist := math.Sqrt(dx*dx + dy*dy)
-			if dist < PICKUP_RADIUS {
-				oldHealth := player.Health
-				player.Health += health.Amount
+			if dist >= PICKUP_RADIUS {
+				if health.DroppedBy == player.ID {
+					health.DroppedBy = ""
+				}
+				continue
+			}
+			if gs.pickUpConsumable(player, health) {
 				player.Score += 10
 				health.Active = false
-				log.Printf("[PICKUP] Player %s collected health at (%.2f, %.2f), health: %d -> %d", player.ID, health.X, health.Y, oldHealth, player.Health)
+			}
+		}
+
+		for _, armor := range gs.gameState.ArmorPickups {
+			if !armor.Active {
+				continue
+			}
+			dx := player.X - armor.X
+			dy := player.Y - armor.Y
+			dist := math.Sqrt(dx*dx + dy*dy)
+			if dist < PICKUP_RADIUS && gs.pickUpArmor(player, armor) {
+				player.Score += 5
+				armor.Active = false
 			}
 		}
 	}
@@ -765,166 +900,35 @@ func (gs *GameServer) updateGame(tick int) {
 	}
 	gs.gameState.HealthPickups = activeHealth
 
-	if len(gs.gameState.AmmoPickups) < 60 {
-		for i := len(gs.gameState.AmmoPickups); i < 60; i++ {
-			ammoID := fmt.Sprintf("ammo_%d", gs.nextAmmoID)
-			gs.nextAmmoID++
-			x, y := gs.findValidPickupPosition(10, true)
-			ammoPickup := &AmmoPickup{
-				ID:     ammoID,
-				X:      x,
-				Y:      y,
-				Amount: 75,
-				Active: true,
-			}
-			gs.gameState.AmmoPickups[ammoID] = ammoPickup
+	activeArmor := make(map[string]*ArmorPickup)
+	for id, armor := range gs.gameState.ArmorPickups {
+		if armor.Active {
+			activeArmor[id] = armor
 		}
 	}
+	gs.gameState.ArmorPickups = activeArmor
 
-	if len(gs.gameState.WeaponPickups) < 45 {
-		weapons := []string{"pistol", "rifle", "machinegun", "shotgun"}
-		for i := len(gs.gameState.WeaponPickups); i < 45; i++ {
-			weaponID := fmt.Sprintf("weapon_%d", gs.nextAmmoID)
-			gs.nextAmmoID++
-			x, y := gs.findValidPickupPosition(10, true)
-			weaponPickup := &WeaponPickup{
-				ID:     weaponID,
-				X:      x,
-				Y:      y,
-				Weapon: weapons[rand.Intn(len(weapons))],
-				Active: true,
-			}
-			gs.gameState.WeaponPickups[weaponID] = weaponPickup
-		}
-	}
+	gs.topUpPickups(60, 45, 25, 20)
 
-	if len(gs.gameState.HealthPickups) < 25 {
-		for i := len(gs.gameState.HealthPickups); i < 25; i++ {
-			healthID := fmt.Sprintf("health_%d", gs.nextHealthID)
-			gs.nextHealthID++
-			x, y := gs.findValidPickupPosition(12, true)
-			healthPickup := &HealthPickup{
-				ID:     healthID,
-				X:      x,
-				Y:      y,
-				Amount: 75,
-				Active: true,
-			}
-			gs.gameState.HealthPickups[healthID] = healthPickup
-		}
-	}
-
-	if tick%ZONE_RESET_INTERVAL == 0 {
-		if gs.gameState.ZoneRadius > 200 {
-			gs.gameState.ZoneRadius -= ZONE_SHRINK_RATE * 10
-		} else if gs.gameState.ZoneRadius <= 200 {
-			gs.gameState.ZoneRadius = ZONE_INITIAL_SIZE
-			avgX := 0.0
-			avgY := 0.0
-			count := 0
-			for _, p := range gs.gameState.Players {
-				if p.Alive && !strings.HasPrefix(p.ID, "enemy_") {
-					avgX += p.X
-					avgY += p.Y
-					count++
-				}
-			}
-			if count > 0 {
-				avgX /= float64(count)
-				avgY /= float64(count)
-				gs.gameState.ZoneCenter = (avgX + avgY) / 2
-			} else {
-				gs.gameState.ZoneCenter = 0
-			}
-
-			for i := 0; i < 5; i++ {
-				enemyID := fmt.Sprintf("enemy_%d", i+1)
-				enemy := gs.gameState.Players[enemyID]
-
-				if enemy == nil {
-					enemy = &Player{
-						ID:        enemyID,
-						Angle:     0,
-						Health:    1000,
-						Alive:     true,
-						Velocity:  16.67,
-						Ammo:      100,
-						Weapon:    "pistol",
-						Score:     0,
-						Kills:     0,
-						LastShoot: 0,
-					}
-					gs.gameState.Players[enemyID] = enemy
-				}
-
-				enemy.Health = 1000
-				enemy.Alive = true
-				enemy.Ammo = 100
-				enemy.Weapon = "pistol"
-				enemy.Score = 0
-				enemy.Kills = 0
-
-				var ok bool
-				enemy.X, enemy.Y, ok = gs.findValidPosition(0, 0, PLAYER_RADIUS, 100, true)
-				if !ok {
-					log.Printf("Warning: Could not find valid spawn position for bot %s on game reset", enemyID)
-				}
-				enemy.Angle = 0
-				gs.botStates[enemyID] = &BotState{
-					TargetX:       enemy.X,
-					TargetY:       enemy.Y,
-					LastDirChange: 0,
-					MoveAngle:     rand.Float64() * 2 * math.Pi,
-				}
-			}
-
-			for _, ammo := range gs.gameState.AmmoPickups {
-				if !ammo.Active {
-					ammo.Active = true
-					x, y := gs.findValidPickupPosition(10, true)
-					ammo.X = x
-					ammo.Y = y
-				}
-			}
-
-			for _, weapon := range gs.gameState.WeaponPickups {
-				if !weapon.Active {
-					weapon.Active = true
-					x, y := gs.findValidPickupPosition(10, true)
-					weapon.X = x
-					weapon.Y = y
-				}
-			}
-
-			for _, health := range gs.gameState.HealthPickups {
-				if !health.Active {
-					health.Active = true
-					x, y := gs.findValidPickupPosition(12, true)
-					health.X = x
-					health.Y = y
-				}
-			}
-		}
+	if gs.gameState.Phase == PHASE_PLAYING {
+		gs.updateZone(tick)
 	}
 
+	gs.balanceBots()
 	gs.updateBots(tick)
 
-	aliveCount := 0
-	zoneDamagePerTick := calculateZoneDamagePerTick(gs.gameState.ZoneRadius)
+	zoneDamagePerTick := gs.zoneDamagePerTick()
 
 	for _, player := range gs.gameState.Players {
-		if player.Alive {
-			aliveCount++
-			dx := player.X - gs.gameState.ZoneCenter
-			dy := player.Y - gs.gameState.ZoneCenter
-			distFromZone := math.Sqrt(dx*dx + dy*dy)
-			if distFromZone > gs.gameState.ZoneRadius {
+		if player.Alive && gs.gameState.Phase == PHASE_PLAYING {
+			if !gs.isInsideZone(player.X, player.Y, 0) {
 				gs.zoneDamageAccumMu.Lock()
 				gs.zoneDamageAccum[player.ID] += zoneDamagePerTick
 				accumulatedDamage := gs.zoneDamageAccum[player.ID]
 				if accumulatedDamage >= 1.0 {
 					damageToApply := int(accumulatedDamage)
 					player.Health -= damageToApply
+					interruptHeal(player, "zone")
 					gs.zoneDamageAccum[player.ID] = accumulatedDamage - float64(damageToApply)
 				}
 				gs.zoneDamageAccumMu.Unlock()
@@ -932,6 +936,7 @@ func (gs *GameServer) updateGame(tick int) {
 				if player.Health <= 0 {
 					player.Health = 0
 					player.Alive = false
+					gs.dropLoot(player)
 					gs.zoneDamageAccumMu.Lock()
 					delete(gs.zoneDamageAccum, player.ID)
 					gs.zoneDamageAccumMu.Unlock()
@@ -944,15 +949,8 @@ func (gs *GameServer) updateGame(tick int) {
 		}
 	}
 
-	if aliveCount <= 1 && gs.gameState.Phase == "playing" {
-		gs.gameState.Phase = "finished"
-		for _, p := range gs.gameState.Players {
-			if p.Alive {
-				gs.gameState.Winner = p.ID
-				break
-			}
-		}
-	}
+	gs.updateMatchPhase(tick)
+	gs.recordPositionHistory(tick)
 
 	playersToSave := make([]*Player, 0)
 	for _, player := range gs.gameState.Players {
@@ -972,136 +970,166 @@ func (gs *GameServer) createDynamicState() *DynamicState {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
 
-	viewportRadius := 2000.0
-
-	playerPositions := make([]struct{ X, Y float64 }, 0, len(gs.gameState.Players))
-	for _, player := range gs.gameState.Players {
-		if player.Alive {
-			playerPositions = append(playerPositions, struct{ X, Y float64 }{player.X, player.Y})
-		}
+	dynamic := &DynamicState{
+		Players:          make(map[string]*Player),
+		Bullets:          make(map[string]*Bullet),
+		AmmoPickups:      make(map[string]*AmmoPickup),
+		WeaponPickups:    make(map[string]*WeaponPickup),
+		HealthPickups:    make(map[string]*HealthPickup),
+		ArmorPickups:     make(map[string]*ArmorPickup),
+		ZoneCenterX:      gs.gameState.ZoneCenterX,
+		ZoneCenterY:      gs.gameState.ZoneCenterY,
+		ZoneRadius:       gs.gameState.ZoneRadius,
+		NextZoneX:        gs.gameState.NextZoneX,
+		NextZoneY:        gs.gameState.NextZoneY,
+		NextZoneRadius:   gs.gameState.NextZoneRadius,
+		ZoneStage:        gs.gameState.ZoneStage,
+		ZoneState:        gs.gameState.ZoneState,
+		ZoneStateEndTick: gs.gameState.ZoneStateEndTick,
+		GameTime:         gs.gameState.GameTime,
+		Phase:            gs.gameState.Phase,
+		PhaseEndTick:     gs.gameState.PhaseEndTick,
+		MatchID:          gs.gameState.MatchID,
+		Winner:           gs.gameState.Winner,
+		Inventories:      make(map[string]*Inventory),
+		grid:             NewSpatialGrid(INTEREST_GRID_CELL_SIZE),
 	}
 
-	buildings := make([]Building, 0)
-	trees := make([]Tree, 0)
-
-	if len(playerPositions) > 0 {
-		viewportRadiusSq := viewportRadius * viewportRadius
-		for _, building := range gs.gameState.Buildings {
-			buildingCenterX := building.X + building.Width/2
-			buildingCenterY := building.Y + building.Height/2
-			for _, pos := range playerPositions {
-				dx := buildingCenterX - pos.X
-				dy := buildingCenterY - pos.Y
-				if dx*dx+dy*dy <= viewportRadiusSq {
-					buildings = append(buildings, building)
-					break
-				}
-			}
-		}
-
-		for _, tree := range gs.gameState.Trees {
-			for _, pos := range playerPositions {
-				dx := tree.X - pos.X
-				dy := tree.Y - pos.Y
-				if dx*dx+dy*dy <= viewportRadiusSq {
-					trees = append(trees, tree)
-					break
-				}
-			}
-		}
-	} else {
-		buildings = make([]Building, len(gs.gameState.Buildings))
-		copy(buildings, gs.gameState.Buildings)
-		trees = make([]Tree, len(gs.gameState.Trees))
-		copy(trees, gs.gameState.Trees)
+	// Only entities some client can see are copied and indexed.
+	cells := gs.interestCells()
+	visible := func(x, y float64) bool {
+		return cells[interestCell(x, y)]
 	}
 
-	dynamic := &DynamicState{
-		Players:       make(map[string]*Player),
-		Bullets:       make(map[string]*Bullet),
-		AmmoPickups:   make(map[string]*AmmoPickup),
-		WeaponPickups: make(map[string]*WeaponPickup),
-		HealthPickups: make(map[string]*HealthPickup),
-		Buildings:     buildings,
-		Trees:         trees,
-		ZoneCenter:    gs.gameState.ZoneCenter,
-		ZoneRadius:    gs.gameState.ZoneRadius,
-		GameTime:      gs.gameState.GameTime,
-		Phase:         gs.gameState.Phase,
-		Winner:        gs.gameState.Winner,
+	tick := gs.currentTick
+	for id, player := range gs.gameState.Players {
+		if !visible(player.X, player.Y) {
+			continue
+		}
+		copied := &Player{
+			ID:             player.ID,
+			X:              player.X,
+			Y:              player.Y,
+			Angle:          player.Angle,
+			Health:         player.Health,
+			Alive:          player.Alive,
+			Velocity:       player.Velocity,
+			Ammo:           player.Ammo,
+			Magazine:       player.Magazine,
+			Weapon:         player.Weapon,
+			Score:          player.Score,
+			Kills:          player.Kills,
+			IsBot:          player.IsBot,
+			Reloading:      player.Reloading,
+			ReloadProgress: reloadProgress(player, tick),
+
+			VestTier:         player.VestTier,
+			VestDurability:   player.VestDurability,
+			HelmetTier:       player.HelmetTier,
+			HelmetDurability: player.HelmetDurability,
+
+			Healing:      player.Healing,
+			HealProgress: healProgress(player, tick),
+			Boost:        math.Ceil(player.Boost),
+		}
+		dynamic.Players[id] = copied
+		dynamic.indexEntity(copied.X, copied.Y, copied)
 	}
 
-	for id, player := range gs.gameState.Players {
-		dynamic.Players[id] = &Player{
-			ID:       player.ID,
-			X:        player.X,
-			Y:        player.Y,
-			Angle:    player.Angle,
-			Health:   player.Health,
-			Alive:    player.Alive,
-			Velocity: player.Velocity,
-			Ammo:     player.Ammo,
-			Weapon:   player.Weapon,
-			Score:    player.Score,
-			Kills:    player.Kills,
+	// Clients only ever see their own inventory.
+	for _, client := range gs.clients {
+		if player := gs.gameState.Players[client.player.ID]; player != nil && player.Inventory != nil {
+			dynamic.Inventories[player.ID] = snapshotInventory(player)
 		}
 	}
 
 	for id, bullet := range gs.gameState.Bullets {
-		if bullet.Active {
-			dynamic.Bullets[id] = &Bullet{
-				ID:       bullet.ID,
-				PlayerID: bullet.PlayerID,
-				X:        bullet.X,
-				Y:        bullet.Y,
-				Angle:    bullet.Angle,
-				Speed:    bullet.Speed,
-				Active:   bullet.Active,
-				Weapon:   bullet.Weapon,
-			}
+		if !bullet.Active || !visible(bullet.X, bullet.Y) {
+			continue
 		}
+		copied := &Bullet{
+			ID:       bullet.ID,
+			PlayerID: bullet.PlayerID,
+			X:        bullet.X,
+			Y:        bullet.Y,
+			Angle:    bullet.Angle,
+			Speed:    bullet.Speed,
+			Active:   bullet.Active,
+			Weapon:   bullet.Weapon,
+		}
+		dynamic.Bullets[id] = copied
+		dynamic.indexEntity(copied.X, copied.Y, copied)
 	}
 
 	for id, ammo := range gs.gameState.AmmoPickups {
-		if ammo.Active {
-			dynamic.AmmoPickups[id] = &AmmoPickup{
-				ID:     ammo.ID,
-				X:      ammo.X,
-				Y:      ammo.Y,
-				Amount: ammo.Amount,
-				Active: ammo.Active,
-			}
+		if !ammo.Active || !visible(ammo.X, ammo.Y) {
+			continue
+		}
+		copied := &AmmoPickup{
+			ID:     ammo.ID,
+			X:      ammo.X,
+			Y:      ammo.Y,
+			Amount: ammo.Amount,
+			Active: ammo.Active,
 		}
+		dynamic.AmmoPickups[id] = copied
+		dynamic.indexEntity(copied.X, copied.Y, copied)
 	}
 
 	for id, weapon := range gs.gameState.WeaponPickups {
-		if weapon.Active {
-			dynamic.WeaponPickups[id] = &WeaponPickup{
-				ID:     weapon.ID,
-				X:      weapon.X,
-				Y:      weapon.Y,
-				Weapon: weapon.Weapon,
-				Active: weapon.Active,
-			}
+		if !weapon.Active || !visible(weapon.X, weapon.Y) {
+			continue
+		}
+		copied := &WeaponPickup{
+			ID:     weapon.ID,
+			X:      weapon.X,
+			Y:      weapon.Y,
+			Weapon: weapon.Weapon,
+			Active: weapon.Active,
 		}
+		dynamic.WeaponPickups[id] = copied
+		dynamic.indexEntity(copied.X, copied.Y, copied)
 	}
 
 	for id, health := range gs.gameState.HealthPickups {
-		if health.Active {
-			dynamic.HealthPickups[id] = &HealthPickup{
-				ID:     health.ID,
-				X:      health.X,
-				Y:      health.Y,
-				Amount: health.Amount,
-				Active: health.Active,
-			}
+		if !health.Active || !visible(health.X, health.Y) {
+			continue
+		}
+		copied := &HealthPickup{
+			ID:     health.ID,
+			X:      health.X,
+			Y:      health.Y,
+			Item:   health.Item,
+			Amount: health.Amount,
+			Active: health.Active,
 		}
+		dynamic.HealthPickups[id] = copied
+		dynamic.indexEntity(copied.X, copied.Y, copied)
+	}
+
+	for id, armor := range gs.gameState.ArmorPickups {
+		if !armor.Active || !visible(armor.X, armor.Y) {
+			continue
+		}
+		copied := &ArmorPickup{
+			ID:         armor.ID,
+			X:          armor.X,
+			Y:          armor.Y,
+			Kind:       armor.Kind,
+			Tier:       armor.Tier,
+			Durability: armor.Durability,
+			Active:     armor.Active,
+		}
+		dynamic.ArmorPickups[id] = copied
+		dynamic.indexEntity(copied.X, copied.Y, copied)
 	}
 
 	return dynamic
 }
 
-func (gs *GameServer) sendChunksToClient(client *clientConn, playerX, playerY float64) {
+// sendChunksToClient writes any chunks around the player the client hasn't
+// seen yet. Only the client's writer goroutine may call it.
+func (gs *GameServer) sendChunksToClient(client *clientConn, playerX, playerY float64) error {
 	chunkRadius := 2
 	centerChunkX := int(math.Floor(playerX / CHUNK_SIZE))
 	centerChunkY := int(math.Floor(playerY / CHUNK_SIZE))
@@ -1109,7 +1137,6 @@ func (gs *GameServer) sendChunksToClient(client *clientConn, playerX, playerY fl
 	chunksToSend := make([]*WorldChunk, 0)
 
 	gs.chunkDataMu.RLock()
-	client.writeMu.Lock()
 	for dx := -chunkRadius; dx <= chunkRadius; dx++ {
 		for dy := -chunkRadius; dy <= chunkRadius; dy++ {
 			chunkX := centerChunkX + dx
@@ -1124,20 +1151,17 @@ func (gs *GameServer) sendChunksToClient(client *clientConn, playerX, playerY fl
 			}
 		}
 	}
-	client.writeMu.Unlock()
 	gs.chunkDataMu.RUnlock()
 
-	if len(chunksToSend) > 0 {
-		chunkMsg := map[string]interface{}{
-			"type":   "worldChunks",
-			"chunks": chunksToSend,
-		}
-
-		client.writeMu.Lock()
-		jsonData, _ := json.Marshal(chunkMsg)
-		client.conn.WriteMessage(websocket.TextMessage, jsonData)
-		client.writeMu.Unlock()
+	if len(chunksToSend) == 0 {
+		return nil
+	}
+	messageType, data, err := client.encodeWorldChunks(chunksToSend)
+	if err != nil {
+		log.Println("Marshal error:", err)
+		return nil
 	}
+	return client.write(messageType, data)
 }
 
 func (gs *GameServer) createStateDiff(client *clientConn) *StateDiff {
@@ -1148,242 +1172,94 @@ func (gs *GameServer) createStateDiff(client *clientConn) *StateDiff {
 func (gs *GameServer) createStateDiffFromState(client *clientConn, currentState *DynamicState) *StateDiff {
 	diff := &StateDiff{
 		Type: "stateDiff",
-		Tick: gs.currentTick,
 	}
 
 	gs.mu.RLock()
+	diff.Tick = gs.currentTick
 	clientPlayer := gs.gameState.Players[client.player.ID]
-	gs.mu.RUnlock()
-
 	clientX := 0.0
 	clientY := 0.0
 	if clientPlayer != nil {
 		clientX = clientPlayer.X
 		clientY = clientPlayer.Y
 	}
+	gs.mu.RUnlock()
 
 	client.lastStateMu.RLock()
 	lastState := client.lastState
 	client.lastStateMu.RUnlock()
 
-	if lastState == nil {
-		diff.Players = make(map[string]*Player)
-		for id, player := range currentState.Players {
-			if id == client.player.ID {
-				diff.Players[id] = player
-			} else {
-				dx := player.X - clientX
-				dy := player.Y - clientY
-				distSq := dx*dx + dy*dy
-				if distSq <= PLAYER_UPDATE_DISTANCE_SQ {
-					diff.Players[id] = player
-				}
-			}
+	view := currentState.interestView(client.player.ID, clientX, clientY)
+	last := lastState
+	if last == nil {
+		last = emptyView
+	}
+
+	diff.Players = make(map[string]*Player)
+	for id, player := range view.Players {
+		if playerChanged(player, last.Players[id]) {
+			diff.Players[id] = player
 		}
+	}
+	diff.RemovedPlayers = removedIDs(view.Players, last.Players)
 
-		diff.Bullets = make(map[string]*Bullet)
-		for id, bullet := range currentState.Bullets {
-			dx := bullet.X - clientX
-			dy := bullet.Y - clientY
-			distSq := dx*dx + dy*dy
-			if distSq <= AOI_RADIUS_SQ {
-				diff.Bullets[id] = bullet
-			}
+	diff.Bullets = make(map[string]*Bullet)
+	for id, bullet := range view.Bullets {
+		lastBullet := last.Bullets[id]
+		if lastBullet == nil || !floatsEqual(bullet.X, lastBullet.X, COORD_EPSILON) || !floatsEqual(bullet.Y, lastBullet.Y, COORD_EPSILON) ||
+			!floatsEqual(bullet.Angle, lastBullet.Angle, ANGLE_EPSILON) || bullet.Active != lastBullet.Active {
+			diff.Bullets[id] = bullet
 		}
+	}
+	diff.RemovedBullets = removedIDs(view.Bullets, last.Bullets)
 
-		diff.AmmoPickups = make(map[string]*AmmoPickup)
-		for id, ammo := range currentState.AmmoPickups {
-			dx := ammo.X - clientX
-			dy := ammo.Y - clientY
-			distSq := dx*dx + dy*dy
-			if distSq <= AOI_RADIUS_SQ {
-				diff.AmmoPickups[id] = ammo
-			}
+	diff.AmmoPickups = make(map[string]*AmmoPickup)
+	for id, ammo := range view.AmmoPickups {
+		lastAmmo := last.AmmoPickups[id]
+		if lastAmmo == nil || ammo.Active != lastAmmo.Active {
+			diff.AmmoPickups[id] = ammo
 		}
+	}
+	diff.RemovedAmmo = removedIDs(view.AmmoPickups, last.AmmoPickups)
 
-		diff.WeaponPickups = make(map[string]*WeaponPickup)
-		for id, weapon := range currentState.WeaponPickups {
-			dx := weapon.X - clientX
-			dy := weapon.Y - clientY
-			distSq := dx*dx + dy*dy
-			if distSq <= AOI_RADIUS_SQ {
-				diff.WeaponPickups[id] = weapon
-			}
+	diff.WeaponPickups = make(map[string]*WeaponPickup)
+	for id, weapon := range view.WeaponPickups {
+		lastWeapon := last.WeaponPickups[id]
+		if lastWeapon == nil || weapon.Active != lastWeapon.Active {
+			diff.WeaponPickups[id] = weapon
 		}
+	}
+	diff.RemovedWeapons = removedIDs(view.WeaponPickups, last.WeaponPickups)
 
-		diff.HealthPickups = make(map[string]*HealthPickup)
-		for id, health := range currentState.HealthPickups {
-			dx := health.X - clientX
-			dy := health.Y - clientY
-			distSq := dx*dx + dy*dy
-			if distSq <= AOI_RADIUS_SQ {
-				diff.HealthPickups[id] = health
-			}
+	diff.HealthPickups = make(map[string]*HealthPickup)
+	for id, health := range view.HealthPickups {
+		lastHealth := last.HealthPickups[id]
+		if lastHealth == nil || health.Active != lastHealth.Active || health.Amount != lastHealth.Amount {
+			diff.HealthPickups[id] = health
+		}
+	}
+	diff.RemovedHealth = removedIDs(view.HealthPickups, last.HealthPickups)
+
+	diff.ArmorPickups = make(map[string]*ArmorPickup)
+	for id, armor := range view.ArmorPickups {
+		lastArmor := last.ArmorPickups[id]
+		if lastArmor == nil || armor.Active != lastArmor.Active {
+			diff.ArmorPickups[id] = armor
 		}
+	}
+	diff.RemovedArmor = removedIDs(view.ArmorPickups, last.ArmorPickups)
 
-		diff.ZoneCenter = currentState.ZoneCenter
-		diff.ZoneRadius = currentState.ZoneRadius
+	if lastState == nil {
+		diff.Zone = currentState.zone()
 		diff.GameTime = currentState.GameTime
 		diff.Phase = currentState.Phase
+		diff.PhaseEndTick = currentState.PhaseEndTick
+		diff.MatchID = currentState.MatchID
 		diff.Winner = currentState.Winner
+		diff.Inventory = currentState.Inventories[client.player.ID]
 	} else {
-		diff.Players = make(map[string]*Player)
-		for id, player := range currentState.Players {
-			if id == client.player.ID {
-				lastPlayer := lastState.Players[id]
-				if lastPlayer == nil || !floatsEqual(player.X, lastPlayer.X, COORD_EPSILON) || !floatsEqual(player.Y, lastPlayer.Y, COORD_EPSILON) ||
-					!floatsEqual(player.Angle, lastPlayer.Angle, ANGLE_EPSILON) || player.Health != lastPlayer.Health ||
-					player.Ammo != lastPlayer.Ammo || player.Weapon != lastPlayer.Weapon ||
-					player.Alive != lastPlayer.Alive || player.Score != lastPlayer.Score ||
-					player.Kills != lastPlayer.Kills {
-					diff.Players[id] = player
-				}
-			} else {
-				dx := player.X - clientX
-				dy := player.Y - clientY
-				distSq := dx*dx + dy*dy
-				if distSq <= PLAYER_UPDATE_DISTANCE_SQ {
-					lastPlayer := lastState.Players[id]
-					if lastPlayer == nil || !floatsEqual(player.X, lastPlayer.X, COORD_EPSILON) || !floatsEqual(player.Y, lastPlayer.Y, COORD_EPSILON) ||
-						!floatsEqual(player.Angle, lastPlayer.Angle, ANGLE_EPSILON) || player.Health != lastPlayer.Health ||
-						player.Ammo != lastPlayer.Ammo || player.Weapon != lastPlayer.Weapon ||
-						player.Alive != lastPlayer.Alive || player.Score != lastPlayer.Score ||
-						player.Kills != lastPlayer.Kills {
-						diff.Players[id] = player
-					}
-				}
-			}
-		}
-
-		diff.RemovedPlayers = make([]string, 0)
-		for id := range lastState.Players {
-			if currentState.Players[id] == nil {
-				diff.RemovedPlayers = append(diff.RemovedPlayers, id)
-			}
-		}
-
-		diff.Bullets = make(map[string]*Bullet)
-		for id, bullet := range currentState.Bullets {
-			dx := bullet.X - clientX
-			dy := bullet.Y - clientY
-			distSq := dx*dx + dy*dy
-			if distSq <= AOI_RADIUS_SQ {
-				lastBullet := lastState.Bullets[id]
-				if lastBullet == nil || !floatsEqual(bullet.X, lastBullet.X, COORD_EPSILON) || !floatsEqual(bullet.Y, lastBullet.Y, COORD_EPSILON) ||
-					!floatsEqual(bullet.Angle, lastBullet.Angle, ANGLE_EPSILON) || bullet.Active != lastBullet.Active {
-					diff.Bullets[id] = bullet
-				}
-			}
-		}
-
-		diff.RemovedBullets = make([]string, 0)
-		for id := range lastState.Bullets {
-			if currentState.Bullets[id] == nil || !currentState.Bullets[id].Active {
-				dx := 0.0
-				dy := 0.0
-				if lastBullet, exists := lastState.Bullets[id]; exists {
-					dx = lastBullet.X - clientX
-					dy = lastBullet.Y - clientY
-				}
-				distSq := dx*dx + dy*dy
-				if distSq <= AOI_RADIUS_SQ {
-					diff.RemovedBullets = append(diff.RemovedBullets, id)
-				}
-			}
-		}
-
-		diff.AmmoPickups = make(map[string]*AmmoPickup)
-		for id, ammo := range currentState.AmmoPickups {
-			dx := ammo.X - clientX
-			dy := ammo.Y - clientY
-			distSq := dx*dx + dy*dy
-			if distSq <= AOI_RADIUS_SQ {
-				lastAmmo := lastState.AmmoPickups[id]
-				if lastAmmo == nil || ammo.Active != lastAmmo.Active {
-					diff.AmmoPickups[id] = ammo
-				}
-			}
-		}
-
-		diff.RemovedAmmo = make([]string, 0)
-		for id := range lastState.AmmoPickups {
-			if currentState.AmmoPickups[id] == nil || !currentState.AmmoPickups[id].Active {
-				dx := 0.0
-				dy := 0.0
-				if lastAmmo, exists := lastState.AmmoPickups[id]; exists {
-					dx = lastAmmo.X - clientX
-					dy = lastAmmo.Y - clientY
-				}
-				distSq := dx*dx + dy*dy
-				if distSq <= AOI_RADIUS_SQ {
-					diff.RemovedAmmo = append(diff.RemovedAmmo, id)
-				}
-			}
-		}
-
-		diff.WeaponPickups = make(map[string]*WeaponPickup)
-		for id, weapon := range currentState.WeaponPickups {
-			dx := weapon.X - clientX
-			dy := weapon.Y - clientY
-			distSq := dx*dx + dy*dy
-			if distSq <= AOI_RADIUS_SQ {
-				lastWeapon := lastState.WeaponPickups[id]
-				if lastWeapon == nil || weapon.Active != lastWeapon.Active {
-					diff.WeaponPickups[id] = weapon
-				}
-			}
-		}
-
-		diff.RemovedWeapons = make([]string, 0)
-		for id := range lastState.WeaponPickups {
-			if currentState.WeaponPickups[id] == nil || !currentState.WeaponPickups[id].Active {
-				dx := 0.0
-				dy := 0.0
-				if lastWeapon, exists := lastState.WeaponPickups[id]; exists {
-					dx = lastWeapon.X - clientX
-					dy = lastWeapon.Y - clientY
-				}
-				distSq := dx*dx + dy*dy
-				if distSq <= AOI_RADIUS_SQ {
-					diff.RemovedWeapons = append(diff.RemovedWeapons, id)
-				}
-			}
-		}
-
-		diff.HealthPickups = make(map[string]*HealthPickup)
-		for id, health := range currentState.HealthPickups {
-			dx := health.X - clientX
-			dy := health.Y - clientY
-			distSq := dx*dx + dy*dy
-			if distSq <= AOI_RADIUS_SQ {
-				lastHealth := lastState.HealthPickups[id]
-				if lastHealth == nil || health.Active != lastHealth.Active {
-					diff.HealthPickups[id] = health
-				}
-			}
-		}
-
-		diff.RemovedHealth = make([]string, 0)
-		for id := range lastState.HealthPickups {
-			if currentState.HealthPickups[id] == nil || !currentState.HealthPickups[id].Active {
-				dx := 0.0
-				dy := 0.0
-				if lastHealth, exists := lastState.HealthPickups[id]; exists {
-					dx = lastHealth.X - clientX
-					dy = lastHealth.Y - clientY
-				}
-				distSq := dx*dx + dy*dy
-				if distSq <= AOI_RADIUS_SQ {
-					diff.RemovedHealth = append(diff.RemovedHealth, id)
-				}
-			}
-		}
-
-		if !floatsEqual(currentState.ZoneCenter, lastState.ZoneCenter, COORD_EPSILON) {
-			diff.ZoneCenter = currentState.ZoneCenter
-		}
-		if !floatsEqual(currentState.ZoneRadius, lastState.ZoneRadius, COORD_EPSILON) {
-			diff.ZoneRadius = currentState.ZoneRadius
+		if zoneChanged(currentState, lastState) {
+			diff.Zone = currentState.zone()
 		}
 		if currentState.GameTime != lastState.GameTime {
 			diff.GameTime = currentState.GameTime
@@ -1391,80 +1267,80 @@ func (gs *GameServer) createStateDiffFromState(client *clientConn, currentState
 		if currentState.Phase != lastState.Phase {
 			diff.Phase = currentState.Phase
 		}
+		if currentState.PhaseEndTick != lastState.PhaseEndTick {
+			diff.PhaseEndTick = currentState.PhaseEndTick
+		}
+		if currentState.MatchID != lastState.MatchID {
+			diff.MatchID = currentState.MatchID
+		}
 		if currentState.Winner != lastState.Winner {
 			diff.Winner = currentState.Winner
 		}
+		if inventory := currentState.Inventories[client.player.ID]; !inventory.equals(lastState.Inventories[client.player.ID]) {
+			diff.Inventory = inventory
+		}
+	}
+	if !client.hasFeature(FEATURE_INVENTORY) {
+		diff.Inventory = nil
+	}
+
+	if client.hasFeature(FEATURE_IMPACTS) {
+		for _, impact := range currentState.Impacts {
+			dx := impact.X - clientX
+			dy := impact.Y - clientY
+			if dx*dx+dy*dy <= AOI_RADIUS_SQ {
+				diff.Impacts = append(diff.Impacts, impact)
+			}
+		}
 	}
 
+	gs.inputQueueMu.Lock()
+	diff.LastProcessedInput = gs.processedInputID[client.player.ID]
+	gs.inputQueueMu.Unlock()
+
 	client.lastStateMu.Lock()
-	client.lastState = currentState
+	client.lastState = view
 	client.lastStateMu.Unlock()
 
 	return diff
 }
 
 func (gs *GameServer) broadcastState() {
-	gs.mu.RLock()
+	gs.mu.Lock()
 	clientsCopy := make([]*clientConn, 0, len(gs.clients))
 	for _, client := range gs.clients {
 		clientsCopy = append(clientsCopy, client)
 	}
-	gs.mu.RUnlock()
+	events := gs.pendingEvents
+	gs.pendingEvents = nil
+	impacts := gs.pendingImpacts
+	gs.pendingImpacts = nil
+	gs.mu.Unlock()
+
+	eventsJSON := make([][]byte, 0, len(events))
+	for _, event := range events {
+		eventJSON, err := json.Marshal(event)
+		if err != nil {
+			log.Println("Marshal error:", err)
+			continue
+		}
+		eventsJSON = append(eventsJSON, eventJSON)
+	}
 
 	if len(clientsCopy) == 0 {
 		return
 	}
 
 	currentState := gs.createDynamicState()
+	currentState.Impacts = impacts
 
 	for _, client := range clientsCopy {
-		go func(c *clientConn) {
-			gs.mu.RLock()
-			player := gs.gameState.Players[c.player.ID]
-			gs.mu.RUnlock()
-
-			if player == nil {
-				return
-			}
-
-			gs.sendChunksToClient(c, player.X, player.Y)
-
-			diff := gs.createStateDiffFromState(c, currentState)
-
-			diffJSON, err := json.Marshal(diff)
-			if err != nil {
-				log.Println("Marshal error:", err)
-				return
-			}
-
-			c.writeMu.Lock()
-			err = c.conn.WriteMessage(websocket.TextMessage, diffJSON)
-			c.writeMu.Unlock()
-			if err != nil {
-				log.Println("Write error:", err)
-			}
-		}(client)
-	}
-}
-
-func (gs *GameServer) startGameLoop() {
-	ticker := time.NewTicker(time.Second / TICK_RATE)
-	broadcastTicker := time.NewTicker(time.Second / BROADCAST_RATE)
-	defer ticker.Stop()
-	defer broadcastTicker.Stop()
-
-	tick := 0
-	for {
-		select {
-		case <-ticker.C:
-			if gs.gameState.Phase == "lobby" && len(gs.gameState.Players) > 0 {
-				gs.gameState.Phase = "playing"
+		for _, eventJSON := range eventsJSON {
+			if !client.enqueue(websocket.TextMessage, eventJSON) {
+				break
 			}
-			gs.updateGame(tick)
-			tick++
-		case <-broadcastTicker.C:
-			gs.broadcastState()
 		}
+		client.queueState(currentState)
 	}
 }
 
@@ -1475,11 +1351,24 @@ func generatePlayerID() string {
 func main() {
 	rand.Seed(time.Now().UnixNano())
 
-	server := NewGameServer()
+	if err := LoadWeapons(os.Getenv("WEAPONS_CONFIG")); err != nil {
+		log.Fatalf("Failed to load weapons: %v", err)
+	}
 
-	go server.startGameLoop()
+	rooms := NewRoomManager()
+	if timeoutEnv := os.Getenv("MATCHMAKING_TIMEOUT"); timeoutEnv != "" {
+		timeout, err := time.ParseDuration(timeoutEnv)
+		if err != nil {
+			log.Fatalf("Invalid MATCHMAKING_TIMEOUT: %v", err)
+		}
+		rooms.matchmaker.timeout = timeout
+	}
+	go rooms.matchmaker.run()
+	go rooms.cleanupRooms()
 
-	http.HandleFunc("/ws", server.handleConnection)
+	http.HandleFunc("/ws", rooms.handleConnection)
+	http.HandleFunc("/lobbies", rooms.handleCreateLobby)
+	http.HandleFunc("/metrics", rooms.handleMetrics)
 
 	clientDir := "./client/dist"
 	if _, err := os.Stat(clientDir); os.IsNotExist(err) {
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+)
+
+func (gs *GameServer) setPhase(phase string, duration int, tick int) {
+	previous := gs.gameState.Phase
+	gs.gameState.Phase = phase
+	gs.gameState.PhaseEndTick = 0
+	if duration > 0 {
+		gs.gameState.PhaseEndTick = tick + duration
+	}
+
+	msg := &PhaseMessage{
+		Type:          "phase",
+		Phase:         phase,
+		PreviousPhase: previous,
+		MatchID:       gs.gameState.MatchID,
+		Tick:          tick,
+		EndTick:       gs.gameState.PhaseEndTick,
+		Countdown:     int(math.Ceil(float64(duration) / TICK_RATE)),
+		Winner:        gs.gameState.Winner,
+	}
+	gs.pendingEvents = append(gs.pendingEvents, msg)
+
+	log.Printf("[MATCH %d] Phase %s -> %s at tick %d (ends at %d)", gs.gameState.MatchID, previous, phase, tick, gs.gameState.PhaseEndTick)
+}
+
+func (gs *GameServer) updateMatchPhase(tick int) {
+	humans := 0
+	alive := 0
+	humansAlive := 0
+	for _, p := range gs.gameState.Players {
+		if !p.IsBot {
+			humans++
+			if p.Alive {
+				humansAlive++
+			}
+		}
+		if p.Alive {
+			alive++
+		}
+	}
+
+	if humans == 0 && gs.gameState.Phase != PHASE_LOBBY {
+		log.Printf("[MATCH %d] No players left, resetting", gs.gameState.MatchID)
+		gs.resetMatch(tick)
+		return
+	}
+
+	switch gs.gameState.Phase {
+	case PHASE_LOBBY:
+		// Private lobbies wait for the host instead of counting down.
+		if gs.lobby != nil {
+			if gs.lobby.startRequested {
+				gs.lobby.startRequested = false
+				gs.setPhase(PHASE_WARMUP, WARMUP_TICKS, tick)
+			}
+			return
+		}
+		if humans < MATCH_MIN_PLAYERS {
+			if gs.gameState.PhaseEndTick != 0 {
+				gs.setPhase(PHASE_LOBBY, 0, tick)
+			}
+			return
+		}
+		if gs.gameState.PhaseEndTick == 0 {
+			gs.setPhase(PHASE_LOBBY, LOBBY_COUNTDOWN_TICKS, tick)
+		} else if tick >= gs.gameState.PhaseEndTick {
+			gs.setPhase(PHASE_WARMUP, WARMUP_TICKS, tick)
+		}
+	case PHASE_WARMUP:
+		if tick >= gs.gameState.PhaseEndTick {
+			gs.startMatch(tick)
+		}
+	case PHASE_PLAYING:
+		// Bots never fight each other, so the match also ends once every
+		// human is down or the final circle has run its course. A match that
+		// started with a single participant has no last one standing.
+		lastStanding := gs.participants > 1 && alive <= 1
+		timeUp := gs.gameState.ZoneState == ZONE_STATE_FINAL && tick >= gs.gameState.ZoneStateEndTick
+		if lastStanding || humansAlive == 0 || timeUp {
+			gs.gameState.Winner = gs.leadingSurvivor()
+			gs.setPhase(PHASE_FINISHED, RESULTS_TICKS, tick)
+		}
+	case PHASE_FINISHED:
+		if tick >= gs.gameState.PhaseEndTick {
+			gs.resetMatch(tick)
+		}
+	}
+}
+
+// leadingSurvivor returns the living player with the most kills, ties going
+// to the healthier one, or "" if nobody is alive.
+func (gs *GameServer) leadingSurvivor() string {
+	var best *Player
+	for _, p := range gs.gameState.Players {
+		if !p.Alive {
+			continue
+		}
+		if best == nil || p.Kills > best.Kills || (p.Kills == best.Kills && p.Health > best.Health) {
+			best = p
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.ID
+}
+
+func (gs *GameServer) startMatch(tick int) {
+	gs.gameState.Bullets = make(map[string]*Bullet)
+	for _, p := range gs.gameState.Players {
+		if !p.Alive {
+			p.Ammo = 100
+			resetInventory(p, gs.rules.startingWeapon())
+			resetArmor(p)
+		}
+		p.Health = MAX_HEALTH
+		p.Alive = true
+		p.Boost = 0
+		p.Score = 0
+		p.Kills = 0
+	}
+	gs.participants = len(gs.gameState.Players)
+	gs.startZone(tick)
+	gs.setPhase(PHASE_PLAYING, 0, tick)
+}
+
+func (gs *GameServer) resetMatch(tick int) {
+	gs.gameState.MatchID++
+	gs.gameState.Winner = ""
+	gs.resetZone()
+	gs.gameState.Bullets = make(map[string]*Bullet)
+
+	gs.gameState.AmmoPickups = make(map[string]*AmmoPickup)
+	gs.gameState.WeaponPickups = make(map[string]*WeaponPickup)
+	gs.gameState.HealthPickups = make(map[string]*HealthPickup)
+	gs.gameState.ArmorPickups = make(map[string]*ArmorPickup)
+	gs.topUpPickups(120, 75, 40, 30)
+
+	gs.zoneDamageAccumMu.Lock()
+	gs.zoneDamageAccum = make(map[string]float64)
+	gs.zoneDamageAccumMu.Unlock()
+
+	gs.respawnBots()
+
+	for _, p := range gs.gameState.Players {
+		if p.IsBot {
+			continue
+		}
+		p.Health = MAX_HEALTH
+		p.Alive = true
+		p.Boost = 0
+		p.Ammo = 100
+		p.Score = 0
+		p.Kills = 0
+		p.LastShoot = 0
+		p.Angle = 0
+		resetInventory(p, gs.rules.startingWeapon())
+		resetArmor(p)
+		var ok bool
+		p.X, p.Y, ok = gs.findValidPosition(0, 0, PLAYER_RADIUS, 100, true)
+		if !ok {
+			log.Printf("Warning: Could not find valid spawn position for player %s on match reset", p.ID)
+		}
+	}
+
+	gs.setPhase(PHASE_LOBBY, 0, tick)
+}
+
+func (gs *GameServer) topUpPickups(ammoTarget, weaponTarget, healthTarget, armorTarget int) {
+	for i := len(gs.gameState.AmmoPickups); i < ammoTarget; i++ {
+		ammoID := fmt.Sprintf("ammo_%d", gs.nextAmmoID)
+		gs.nextAmmoID++
+		x, y := gs.findValidPickupPosition(10, true)
+		gs.gameState.AmmoPickups[ammoID] = &AmmoPickup{
+			ID:     ammoID,
+			X:      x,
+			Y:      y,
+			Amount: 75,
+			Active: true,
+		}
+	}
+
+	for i := len(gs.gameState.WeaponPickups); i < weaponTarget; i++ {
+		weaponID := fmt.Sprintf("weapon_%d", gs.nextAmmoID)
+		gs.nextAmmoID++
+		x, y := gs.findValidPickupPosition(10, true)
+		weapon := gs.rules.Weapons[rand.Intn(len(gs.rules.Weapons))]
+		gs.gameState.WeaponPickups[weaponID] = &WeaponPickup{
+			ID:     weaponID,
+			X:      x,
+			Y:      y,
+			Weapon: weapon,
+			Active: true,
+			Ammo:   GetWeapon(weapon).GetInitialAmmo(),
+		}
+	}
+
+	for i := len(gs.gameState.HealthPickups); i < healthTarget; i++ {
+		x, y := gs.findValidPickupPosition(12, true)
+		item, amount := randomConsumable()
+		gs.spawnConsumablePickup(x, y, item, amount, "")
+	}
+
+	for i := len(gs.gameState.ArmorPickups); i < armorTarget; i++ {
+		x, y := gs.findValidPickupPosition(12, true)
+		kind := ARMOR_VEST
+		if rand.Intn(2) == 0 {
+			kind = ARMOR_HELMET
+		}
+		tier := randomArmorTier()
+		gs.spawnArmorPickup(x, y, kind, tier, armorTiers[tier].Durability)
+	}
+}
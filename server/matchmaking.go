@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Players without a room to return to wait in the matchmaking queue. A match
+// is formed once MATCH_SIZE players are waiting or the longest waiting player
+// has been queued for MATCHMAKING_TIMEOUT; bots take the slots left over.
+// The timeout can be overridden with the MATCHMAKING_TIMEOUT environment
+// variable; with 0s every player starts a match as soon as they queue.
+const (
+	MATCH_SIZE                  = 10
+	MATCHMAKING_TIMEOUT         = 10 * time.Second
+	MATCHMAKING_UPDATE_INTERVAL = time.Second
+	MATCHMAKING_WRITE_TIMEOUT   = time.Second
+)
+
+// ticket is a queued connection. Until room is set the ticket owns all
+// writes to conn, serialized by mu.
+type ticket struct {
+	conn      *websocket.Conn
+	sessionID string
+	caps      Capabilities
+	joined    time.Time
+
+	mu        sync.Mutex
+	cancelled bool
+	room      *Room
+	player    *Player
+}
+
+type Matchmaker struct {
+	mu      sync.Mutex
+	rooms   *RoomManager
+	queue   []*ticket
+	timeout time.Duration
+}
+
+func newMatchmaker(rooms *RoomManager) *Matchmaker {
+	return &Matchmaker{rooms: rooms, timeout: MATCHMAKING_TIMEOUT}
+}
+
+// writeJSON sends a message to a queued player. Callers must hold t.mu.
+func (t *ticket) writeJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.conn.SetWriteDeadline(time.Now().Add(MATCHMAKING_WRITE_TIMEOUT))
+	return t.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// wait queues the connection and reads from it until the player is handed
+// to a room, then carries on reading as that room's client.
+func (mm *Matchmaker) wait(conn *websocket.Conn, sessionID string, caps Capabilities) {
+	t := &ticket{
+		conn:      conn,
+		sessionID: sessionID,
+		caps:      caps,
+		joined:    time.Now(),
+	}
+
+	mm.mu.Lock()
+	mm.queue = append(mm.queue, t)
+	log.Printf("[MATCHMAKING] %s queued at position %d", conn.RemoteAddr(), len(mm.queue))
+	mm.mu.Unlock()
+
+	// A full queue or a zero timeout forms the match right away.
+	mm.update(t.joined)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		messageType, data, err := conn.ReadMessage()
+
+		t.mu.Lock()
+		room, player := t.room, t.player
+		if err != nil {
+			t.cancelled = true
+		} else if room == nil {
+			t.handleQueuedMessage(messageType, data)
+		}
+		t.mu.Unlock()
+
+		if err != nil {
+			if room != nil {
+				room.server.disconnectClient(conn, player.ID)
+			} else {
+				log.Printf("[MATCHMAKING] %s left the queue", conn.RemoteAddr())
+			}
+			conn.Close()
+			return
+		}
+
+		if room != nil {
+			room.server.handleMessage(conn, player, messageType, data)
+			room.server.handleClient(conn, player)
+			return
+		}
+	}
+}
+
+// handleQueuedMessage answers pings while the player waits. Anything else
+// is dropped since there is no game to apply it to yet.
+func (t *ticket) handleQueuedMessage(messageType int, data []byte) {
+	var msg InputMessage
+	if err := readInputMessage(messageType, data, &msg); err != nil || msg.Type != "ping" {
+		return
+	}
+	t.writeJSON(map[string]interface{}{
+		"type": "pong",
+		"time": msg.Time,
+	})
+}
+
+func (mm *Matchmaker) run() {
+	ticker := time.NewTicker(MATCHMAKING_UPDATE_INTERVAL)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		mm.update(now)
+	}
+}
+
+// update forms as many matches as the queue allows and tells everyone still
+// waiting where they stand.
+func (mm *Matchmaker) update(now time.Time) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	waiting := mm.queue[:0]
+	for _, t := range mm.queue {
+		t.mu.Lock()
+		cancelled := t.cancelled
+		t.mu.Unlock()
+		if !cancelled {
+			waiting = append(waiting, t)
+		}
+	}
+	mm.queue = waiting
+
+	for len(mm.queue) >= MATCH_SIZE || (len(mm.queue) > 0 && now.Sub(mm.queue[0].joined) >= mm.timeout) {
+		n := len(mm.queue)
+		if n > MATCH_SIZE {
+			n = MATCH_SIZE
+		}
+		mm.formMatch(mm.queue[:n])
+		mm.queue = mm.queue[n:]
+	}
+
+	for i := range mm.queue {
+		mm.sendStatus(i, now)
+	}
+}
+
+// formMatch opens a room for the given tickets, fills the rest of the match
+// with bots and hands every player over. Callers must hold mm.mu.
+func (mm *Matchmaker) formMatch(tickets []*ticket) {
+	mm.rooms.mu.Lock()
+	room := mm.rooms.createRoom(defaultRules(MATCH_SIZE), nil)
+	mm.rooms.mu.Unlock()
+
+	players := 0
+	for _, t := range tickets {
+		t.mu.Lock()
+		if !t.cancelled {
+			t.player = room.server.acceptClient(t.conn, t.sessionID, t.caps)
+			t.room = room
+			players++
+		}
+		t.mu.Unlock()
+	}
+
+	log.Printf("[MATCHMAKING] Formed match in %s with %d players and %d bots", room.ID, players, MATCH_SIZE-players)
+}
+
+// sendStatus tells the player at index in the queue their position and
+// roughly how long until their match forms. Callers must hold mm.mu.
+func (mm *Matchmaker) sendStatus(index int, now time.Time) {
+	t := mm.queue[index]
+
+	// Each group of MATCH_SIZE players forms a match once its first player
+	// has waited the matchmaking timeout.
+	head := mm.queue[index/MATCH_SIZE*MATCH_SIZE]
+	eta := mm.timeout - now.Sub(head.joined)
+	if eta < 0 {
+		eta = 0
+	}
+
+	msg := &QueueMessage{
+		Type:      "queue",
+		Position:  index + 1,
+		Queued:    len(mm.queue),
+		MatchSize: MATCH_SIZE,
+		ETA:       int(math.Ceil(eta.Seconds())),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancelled || t.room != nil {
+		return
+	}
+	if err := t.writeJSON(msg); err != nil {
+		t.cancelled = true
+		t.conn.Close()
+	}
+}
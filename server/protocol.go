@@ -0,0 +1,989 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Binary wire format. Every binary frame starts with a message type byte.
+// Integers are varints, coordinates are zigzag varints in hundredths of a
+// unit, angles are 16-bit fractions of a full turn and entity IDs of the form
+// "<prefix>_<number>" are sent as a prefix code plus a varint. Optional
+// fields are announced by a leading bitmask so absent values cost nothing.
+const (
+	BINARY_MSG_STATE_DIFF   = 1
+	BINARY_MSG_WORLD_CHUNKS = 2
+	BINARY_MSG_INPUT        = 3
+)
+
+const (
+	ENCODING_JSON   = "json"
+	ENCODING_BINARY = "binary"
+)
+
+var errShortMessage = errors.New("binary message truncated")
+
+// idPrefixes maps entity ID prefixes to their one-byte code. Code 0 means the
+// ID follows as a plain string.
+var idPrefixes = []string{"", "player_", "enemy_", "bullet_", "ammo_", "weapon_", "health_", "armor_"}
+
+// Largest ID number sent as a varint. Browsers decode varints into doubles,
+// so anything above 2^53 would lose precision.
+const maxNumericID = 1 << 53
+
+type binaryWriter struct {
+	buf []byte
+}
+
+func (w *binaryWriter) byte(b byte) {
+	w.buf = append(w.buf, b)
+}
+
+func (w *binaryWriter) uvarint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *binaryWriter) varint(v int64) {
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+func (w *binaryWriter) count(n int) {
+	w.uvarint(uint64(n))
+}
+
+func (w *binaryWriter) bool(b bool) {
+	if b {
+		w.byte(1)
+	} else {
+		w.byte(0)
+	}
+}
+
+func (w *binaryWriter) coord(v float64) {
+	w.varint(int64(math.Round(v * 100)))
+}
+
+func (w *binaryWriter) fraction(v float64) {
+	w.uvarint(uint64(math.Round(math.Max(0, v) * 100)))
+}
+
+func (w *binaryWriter) angle(v float64) {
+	turn := math.Mod(v+math.Pi, 2*math.Pi)
+	if turn < 0 {
+		turn += 2 * math.Pi
+	}
+	q := uint16(int(math.Round(turn/(2*math.Pi)*65536)) & 0xFFFF)
+	w.buf = binary.LittleEndian.AppendUint16(w.buf, q)
+}
+
+func (w *binaryWriter) string(s string) {
+	w.count(len(s))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *binaryWriter) id(id string) {
+	for code := 1; code < len(idPrefixes); code++ {
+		rest, ok := strings.CutPrefix(id, idPrefixes[code])
+		if !ok || rest == "" || (len(rest) > 1 && rest[0] == '0') {
+			continue
+		}
+		n, err := strconv.ParseUint(rest, 10, 64)
+		if err != nil || n >= maxNumericID {
+			continue
+		}
+		w.byte(byte(code))
+		w.uvarint(n)
+		return
+	}
+	w.byte(0)
+	w.string(id)
+}
+
+func (w *binaryWriter) ids(ids []string) {
+	w.count(len(ids))
+	for _, id := range ids {
+		w.id(id)
+	}
+}
+
+type binaryReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *binaryReader) byte() byte {
+	if r.err != nil || r.pos >= len(r.buf) {
+		r.err = errShortMessage
+		return 0
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		r.err = errShortMessage
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *binaryReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.buf[r.pos:])
+	if n <= 0 {
+		r.err = errShortMessage
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *binaryReader) coord() float64 {
+	return float64(r.varint()) / 100
+}
+
+func (r *binaryReader) angle() float64 {
+	if r.err != nil || r.pos+2 > len(r.buf) {
+		r.err = errShortMessage
+		return 0
+	}
+	q := binary.LittleEndian.Uint16(r.buf[r.pos:])
+	r.pos += 2
+	return float64(q)/65536*2*math.Pi - math.Pi
+}
+
+func (r *binaryReader) bool() bool {
+	return r.byte() != 0
+}
+
+func (r *binaryReader) fraction() float64 {
+	return float64(r.uvarint()) / 100
+}
+
+func (r *binaryReader) count() int {
+	n := r.uvarint()
+	// A count can never exceed the bytes left, whatever the entries are.
+	if r.err == nil && n > uint64(len(r.buf)-r.pos) {
+		r.err = errShortMessage
+		return 0
+	}
+	return int(n)
+}
+
+func (r *binaryReader) string() string {
+	n := r.count()
+	if r.err != nil {
+		return ""
+	}
+	s := string(r.buf[r.pos : r.pos+n])
+	r.pos += n
+	return s
+}
+
+func (r *binaryReader) id() string {
+	code := r.byte()
+	if r.err != nil {
+		return ""
+	}
+	if code == 0 {
+		return r.string()
+	}
+	if int(code) >= len(idPrefixes) {
+		r.err = errors.New("unknown id prefix")
+		return ""
+	}
+	return idPrefixes[code] + strconv.FormatUint(r.uvarint(), 10)
+}
+
+func (r *binaryReader) ids() []string {
+	ids := make([]string, r.count())
+	for i := range ids {
+		ids[i] = r.id()
+	}
+	return ids
+}
+
+// Player fields, in the order they appear after a player's change mask.
+const (
+	playerFieldX = iota
+	playerFieldY
+	playerFieldAngle
+	playerFieldHealth
+	playerFieldAlive
+	playerFieldVelocity
+	playerFieldAmmo
+	playerFieldMagazine
+	playerFieldWeapon
+	playerFieldScore
+	playerFieldKills
+	playerFieldReloading
+	playerFieldReloadProgress
+	playerFieldVestTier
+	playerFieldVestDurability
+	playerFieldHelmetTier
+	playerFieldHelmetDurability
+	playerFieldHealing
+	playerFieldHealProgress
+	playerFieldBoost
+	playerFieldIsBot
+	playerFieldCount
+)
+
+// playerChangeMask returns which fields differ from what the client was last
+// sent. Without a previous copy every field is included.
+func playerChangeMask(player, last *Player) uint64 {
+	if last == nil {
+		return 1<<playerFieldCount - 1
+	}
+	var mask uint64
+	set := func(field int, changed bool) {
+		if changed {
+			mask |= 1 << field
+		}
+	}
+	set(playerFieldX, player.X != last.X)
+	set(playerFieldY, player.Y != last.Y)
+	set(playerFieldAngle, player.Angle != last.Angle)
+	set(playerFieldHealth, player.Health != last.Health)
+	set(playerFieldAlive, player.Alive != last.Alive)
+	set(playerFieldVelocity, player.Velocity != last.Velocity)
+	set(playerFieldAmmo, player.Ammo != last.Ammo)
+	set(playerFieldMagazine, player.Magazine != last.Magazine)
+	set(playerFieldWeapon, player.Weapon != last.Weapon)
+	set(playerFieldScore, player.Score != last.Score)
+	set(playerFieldKills, player.Kills != last.Kills)
+	set(playerFieldReloading, player.Reloading != last.Reloading)
+	set(playerFieldReloadProgress, player.ReloadProgress != last.ReloadProgress)
+	set(playerFieldVestTier, player.VestTier != last.VestTier)
+	set(playerFieldVestDurability, player.VestDurability != last.VestDurability)
+	set(playerFieldHelmetTier, player.HelmetTier != last.HelmetTier)
+	set(playerFieldHelmetDurability, player.HelmetDurability != last.HelmetDurability)
+	set(playerFieldHealing, player.Healing != last.Healing)
+	set(playerFieldHealProgress, player.HealProgress != last.HealProgress)
+	set(playerFieldBoost, player.Boost != last.Boost)
+	set(playerFieldIsBot, player.IsBot != last.IsBot)
+	return mask
+}
+
+func (w *binaryWriter) player(player *Player, mask uint64) {
+	w.uvarint(mask)
+	has := func(field int) bool { return mask&(1<<field) != 0 }
+	if has(playerFieldX) {
+		w.coord(player.X)
+	}
+	if has(playerFieldY) {
+		w.coord(player.Y)
+	}
+	if has(playerFieldAngle) {
+		w.angle(player.Angle)
+	}
+	if has(playerFieldHealth) {
+		w.varint(int64(player.Health))
+	}
+	if has(playerFieldAlive) {
+		w.bool(player.Alive)
+	}
+	if has(playerFieldVelocity) {
+		w.coord(player.Velocity)
+	}
+	if has(playerFieldAmmo) {
+		w.varint(int64(player.Ammo))
+	}
+	if has(playerFieldMagazine) {
+		w.varint(int64(player.Magazine))
+	}
+	if has(playerFieldWeapon) {
+		w.string(player.Weapon)
+	}
+	if has(playerFieldScore) {
+		w.varint(int64(player.Score))
+	}
+	if has(playerFieldKills) {
+		w.varint(int64(player.Kills))
+	}
+	if has(playerFieldReloading) {
+		w.bool(player.Reloading)
+	}
+	if has(playerFieldReloadProgress) {
+		w.fraction(player.ReloadProgress)
+	}
+	if has(playerFieldVestTier) {
+		w.varint(int64(player.VestTier))
+	}
+	if has(playerFieldVestDurability) {
+		w.varint(int64(player.VestDurability))
+	}
+	if has(playerFieldHelmetTier) {
+		w.varint(int64(player.HelmetTier))
+	}
+	if has(playerFieldHelmetDurability) {
+		w.varint(int64(player.HelmetDurability))
+	}
+	if has(playerFieldHealing) {
+		w.string(player.Healing)
+	}
+	if has(playerFieldHealProgress) {
+		w.fraction(player.HealProgress)
+	}
+	if has(playerFieldBoost) {
+		w.coord(player.Boost)
+	}
+	if has(playerFieldIsBot) {
+		w.bool(player.IsBot)
+	}
+}
+
+func (r *binaryReader) player(p *Player) {
+	mask := r.uvarint()
+	has := func(field int) bool { return mask&(1<<field) != 0 }
+	if has(playerFieldX) {
+		p.X = r.coord()
+	}
+	if has(playerFieldY) {
+		p.Y = r.coord()
+	}
+	if has(playerFieldAngle) {
+		p.Angle = r.angle()
+	}
+	if has(playerFieldHealth) {
+		p.Health = int(r.varint())
+	}
+	if has(playerFieldAlive) {
+		p.Alive = r.bool()
+	}
+	if has(playerFieldVelocity) {
+		p.Velocity = r.coord()
+	}
+	if has(playerFieldAmmo) {
+		p.Ammo = int(r.varint())
+	}
+	if has(playerFieldMagazine) {
+		p.Magazine = int(r.varint())
+	}
+	if has(playerFieldWeapon) {
+		p.Weapon = r.string()
+	}
+	if has(playerFieldScore) {
+		p.Score = int(r.varint())
+	}
+	if has(playerFieldKills) {
+		p.Kills = int(r.varint())
+	}
+	if has(playerFieldReloading) {
+		p.Reloading = r.bool()
+	}
+	if has(playerFieldReloadProgress) {
+		p.ReloadProgress = r.fraction()
+	}
+	if has(playerFieldVestTier) {
+		p.VestTier = int(r.varint())
+	}
+	if has(playerFieldVestDurability) {
+		p.VestDurability = int(r.varint())
+	}
+	if has(playerFieldHelmetTier) {
+		p.HelmetTier = int(r.varint())
+	}
+	if has(playerFieldHelmetDurability) {
+		p.HelmetDurability = int(r.varint())
+	}
+	if has(playerFieldHealing) {
+		p.Healing = r.string()
+	}
+	if has(playerFieldHealProgress) {
+		p.HealProgress = r.fraction()
+	}
+	if has(playerFieldBoost) {
+		p.Boost = r.coord()
+	}
+	if has(playerFieldIsBot) {
+		p.IsBot = r.bool()
+	}
+}
+
+// StateDiff sections, in the order they follow the section mask.
+const (
+	diffPlayers = iota
+	diffBullets
+	diffAmmo
+	diffWeapons
+	diffHealth
+	diffArmor
+	diffRemovedPlayers
+	diffRemovedBullets
+	diffRemovedAmmo
+	diffRemovedWeapons
+	diffRemovedHealth
+	diffRemovedArmor
+	diffInventory
+	diffImpacts
+	diffZone
+	diffScalars
+)
+
+// Scalar StateDiff fields, in the order they follow the scalar mask. A field
+// is present when it is non-zero, matching the JSON omitempty behaviour.
+const (
+	scalarGameTime = iota
+	scalarPhase
+	scalarPhaseEndTick
+	scalarMatchID
+	scalarWinner
+	scalarLastProcessedInput
+)
+
+// binaryEncoder holds what one client was last sent so players can be
+// encoded as changed fields only.
+type binaryEncoder struct {
+	players map[string]Player
+}
+
+func newBinaryEncoder() *binaryEncoder {
+	return &binaryEncoder{players: make(map[string]Player)}
+}
+
+func (e *binaryEncoder) encodeStateDiff(diff *StateDiff) []byte {
+	w := &binaryWriter{buf: make([]byte, 0, 256)}
+	w.byte(BINARY_MSG_STATE_DIFF)
+	w.uvarint(uint64(diff.Tick))
+
+	var sections uint64
+	section := func(bit int, present bool) {
+		if present {
+			sections |= 1 << bit
+		}
+	}
+	section(diffPlayers, len(diff.Players) > 0)
+	section(diffBullets, len(diff.Bullets) > 0)
+	section(diffAmmo, len(diff.AmmoPickups) > 0)
+	section(diffWeapons, len(diff.WeaponPickups) > 0)
+	section(diffHealth, len(diff.HealthPickups) > 0)
+	section(diffArmor, len(diff.ArmorPickups) > 0)
+	section(diffRemovedPlayers, len(diff.RemovedPlayers) > 0)
+	section(diffRemovedBullets, len(diff.RemovedBullets) > 0)
+	section(diffRemovedAmmo, len(diff.RemovedAmmo) > 0)
+	section(diffRemovedWeapons, len(diff.RemovedWeapons) > 0)
+	section(diffRemovedHealth, len(diff.RemovedHealth) > 0)
+	section(diffRemovedArmor, len(diff.RemovedArmor) > 0)
+	section(diffInventory, diff.Inventory != nil)
+	section(diffImpacts, len(diff.Impacts) > 0)
+	section(diffZone, diff.Zone != nil)
+
+	var scalars uint64
+	scalar := func(bit int, present bool) {
+		if present {
+			scalars |= 1 << bit
+		}
+	}
+	scalar(scalarGameTime, diff.GameTime != 0)
+	scalar(scalarPhase, diff.Phase != "")
+	scalar(scalarPhaseEndTick, diff.PhaseEndTick != 0)
+	scalar(scalarMatchID, diff.MatchID != 0)
+	scalar(scalarWinner, diff.Winner != "")
+	scalar(scalarLastProcessedInput, diff.LastProcessedInput != 0)
+	section(diffScalars, scalars != 0)
+
+	w.uvarint(sections)
+
+	if len(diff.Players) > 0 {
+		w.count(len(diff.Players))
+		for id, player := range diff.Players {
+			var last *Player
+			if prev, ok := e.players[id]; ok {
+				last = &prev
+			}
+			w.id(id)
+			w.player(player, playerChangeMask(player, last))
+			e.players[id] = *player
+		}
+	}
+
+	if len(diff.Bullets) > 0 {
+		w.count(len(diff.Bullets))
+		for id, bullet := range diff.Bullets {
+			w.id(id)
+			w.id(bullet.PlayerID)
+			w.coord(bullet.X)
+			w.coord(bullet.Y)
+			w.angle(bullet.Angle)
+			w.coord(bullet.Speed)
+			w.bool(bullet.Active)
+			w.string(bullet.Weapon)
+		}
+	}
+
+	if len(diff.AmmoPickups) > 0 {
+		w.count(len(diff.AmmoPickups))
+		for id, ammo := range diff.AmmoPickups {
+			w.id(id)
+			w.coord(ammo.X)
+			w.coord(ammo.Y)
+			w.varint(int64(ammo.Amount))
+			w.bool(ammo.Active)
+		}
+	}
+
+	if len(diff.WeaponPickups) > 0 {
+		w.count(len(diff.WeaponPickups))
+		for id, weapon := range diff.WeaponPickups {
+			w.id(id)
+			w.coord(weapon.X)
+			w.coord(weapon.Y)
+			w.string(weapon.Weapon)
+			w.bool(weapon.Active)
+		}
+	}
+
+	if len(diff.HealthPickups) > 0 {
+		w.count(len(diff.HealthPickups))
+		for id, health := range diff.HealthPickups {
+			w.id(id)
+			w.coord(health.X)
+			w.coord(health.Y)
+			w.string(health.Item)
+			w.varint(int64(health.Amount))
+			w.bool(health.Active)
+		}
+	}
+
+	if len(diff.ArmorPickups) > 0 {
+		w.count(len(diff.ArmorPickups))
+		for id, armor := range diff.ArmorPickups {
+			w.id(id)
+			w.coord(armor.X)
+			w.coord(armor.Y)
+			w.string(armor.Kind)
+			w.varint(int64(armor.Tier))
+			w.varint(int64(armor.Durability))
+			w.bool(armor.Active)
+		}
+	}
+
+	if len(diff.RemovedPlayers) > 0 {
+		w.ids(diff.RemovedPlayers)
+		for _, id := range diff.RemovedPlayers {
+			delete(e.players, id)
+		}
+	}
+	if len(diff.RemovedBullets) > 0 {
+		w.ids(diff.RemovedBullets)
+	}
+	if len(diff.RemovedAmmo) > 0 {
+		w.ids(diff.RemovedAmmo)
+	}
+	if len(diff.RemovedWeapons) > 0 {
+		w.ids(diff.RemovedWeapons)
+	}
+	if len(diff.RemovedHealth) > 0 {
+		w.ids(diff.RemovedHealth)
+	}
+	if len(diff.RemovedArmor) > 0 {
+		w.ids(diff.RemovedArmor)
+	}
+
+	if inv := diff.Inventory; inv != nil {
+		w.count(len(inv.Slots))
+		for _, slot := range inv.Slots {
+			w.string(slot.Weapon)
+			w.varint(int64(slot.Magazine))
+		}
+		w.count(inv.Active)
+		w.count(len(inv.Consumables))
+		for name, count := range inv.Consumables {
+			w.string(name)
+			w.varint(int64(count))
+		}
+	}
+
+	if len(diff.Impacts) > 0 {
+		w.count(len(diff.Impacts))
+		for _, impact := range diff.Impacts {
+			w.id(impact.BulletID)
+			w.coord(impact.X)
+			w.coord(impact.Y)
+			w.string(impact.Target)
+			w.id(impact.PlayerID)
+		}
+	}
+
+	if zone := diff.Zone; zone != nil {
+		w.coord(zone.CenterX)
+		w.coord(zone.CenterY)
+		w.coord(zone.Radius)
+		w.coord(zone.NextX)
+		w.coord(zone.NextY)
+		w.coord(zone.NextRadius)
+		w.varint(int64(zone.Stage))
+		w.string(zone.State)
+		w.varint(int64(zone.StateEndTick))
+	}
+
+	if scalars != 0 {
+		w.uvarint(scalars)
+		has := func(bit int) bool { return scalars&(1<<bit) != 0 }
+		if has(scalarGameTime) {
+			w.varint(int64(diff.GameTime))
+		}
+		if has(scalarPhase) {
+			w.string(diff.Phase)
+		}
+		if has(scalarPhaseEndTick) {
+			w.varint(int64(diff.PhaseEndTick))
+		}
+		if has(scalarMatchID) {
+			w.varint(int64(diff.MatchID))
+		}
+		if has(scalarWinner) {
+			w.id(diff.Winner)
+		}
+		if has(scalarLastProcessedInput) {
+			w.varint(int64(diff.LastProcessedInput))
+		}
+	}
+
+	return w.buf
+}
+
+// binaryDecoder is the reading side of binaryEncoder. It keeps the players
+// it has seen so change-masked player updates can be merged, like the client
+// does.
+type binaryDecoder struct {
+	players map[string]Player
+}
+
+func newBinaryDecoder() *binaryDecoder {
+	return &binaryDecoder{players: make(map[string]Player)}
+}
+
+func (d *binaryDecoder) decodeStateDiff(data []byte) (*StateDiff, error) {
+	r := &binaryReader{buf: data}
+	if r.byte() != BINARY_MSG_STATE_DIFF {
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, errors.New("unexpected binary message type")
+	}
+
+	diff := &StateDiff{Type: "stateDiff", Tick: int(r.uvarint())}
+	sections := r.uvarint()
+	has := func(bit int) bool { return sections&(1<<bit) != 0 }
+
+	if has(diffPlayers) {
+		diff.Players = make(map[string]*Player)
+		for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+			id := r.id()
+			player := d.players[id]
+			player.ID = id
+			r.player(&player)
+			d.players[id] = player
+			diff.Players[id] = &player
+		}
+	}
+	if has(diffBullets) {
+		diff.Bullets = make(map[string]*Bullet)
+		for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+			b := &Bullet{ID: r.id(), PlayerID: r.id(), X: r.coord(), Y: r.coord(), Angle: r.angle(),
+				Speed: r.coord(), Active: r.bool(), Weapon: r.string()}
+			diff.Bullets[b.ID] = b
+		}
+	}
+	if has(diffAmmo) {
+		diff.AmmoPickups = make(map[string]*AmmoPickup)
+		for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+			a := &AmmoPickup{ID: r.id(), X: r.coord(), Y: r.coord(), Amount: int(r.varint()), Active: r.bool()}
+			diff.AmmoPickups[a.ID] = a
+		}
+	}
+	if has(diffWeapons) {
+		diff.WeaponPickups = make(map[string]*WeaponPickup)
+		for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+			w := &WeaponPickup{ID: r.id(), X: r.coord(), Y: r.coord(), Weapon: r.string(), Active: r.bool()}
+			diff.WeaponPickups[w.ID] = w
+		}
+	}
+	if has(diffHealth) {
+		diff.HealthPickups = make(map[string]*HealthPickup)
+		for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+			h := &HealthPickup{ID: r.id(), X: r.coord(), Y: r.coord(), Item: r.string(), Amount: int(r.varint()), Active: r.bool()}
+			diff.HealthPickups[h.ID] = h
+		}
+	}
+	if has(diffArmor) {
+		diff.ArmorPickups = make(map[string]*ArmorPickup)
+		for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+			a := &ArmorPickup{ID: r.id(), X: r.coord(), Y: r.coord(), Kind: r.string(),
+				Tier: int(r.varint()), Durability: int(r.varint()), Active: r.bool()}
+			diff.ArmorPickups[a.ID] = a
+		}
+	}
+	if has(diffRemovedPlayers) {
+		diff.RemovedPlayers = r.ids()
+		for _, id := range diff.RemovedPlayers {
+			delete(d.players, id)
+		}
+	}
+	if has(diffRemovedBullets) {
+		diff.RemovedBullets = r.ids()
+	}
+	if has(diffRemovedAmmo) {
+		diff.RemovedAmmo = r.ids()
+	}
+	if has(diffRemovedWeapons) {
+		diff.RemovedWeapons = r.ids()
+	}
+	if has(diffRemovedHealth) {
+		diff.RemovedHealth = r.ids()
+	}
+	if has(diffRemovedArmor) {
+		diff.RemovedArmor = r.ids()
+	}
+	if has(diffInventory) {
+		inv := &Inventory{Slots: make([]InventorySlot, r.count())}
+		for i := range inv.Slots {
+			inv.Slots[i] = InventorySlot{Weapon: r.string(), Magazine: int(r.varint())}
+		}
+		inv.Active = int(r.uvarint())
+		inv.Consumables = make(map[string]int)
+		for i, n := 0, r.count(); i < n && r.err == nil; i++ {
+			name := r.string()
+			inv.Consumables[name] = int(r.varint())
+		}
+		diff.Inventory = inv
+	}
+	if has(diffImpacts) {
+		diff.Impacts = make([]BulletImpact, r.count())
+		for i := range diff.Impacts {
+			diff.Impacts[i] = BulletImpact{BulletID: r.id(), X: r.coord(), Y: r.coord(), Target: r.string(), PlayerID: r.id()}
+		}
+	}
+	if has(diffZone) {
+		diff.Zone = &ZoneDiff{
+			CenterX: r.coord(), CenterY: r.coord(), Radius: r.coord(),
+			NextX: r.coord(), NextY: r.coord(), NextRadius: r.coord(),
+			Stage: int(r.varint()), State: r.string(), StateEndTick: int(r.varint()),
+		}
+	}
+	if has(diffScalars) {
+		scalars := r.uvarint()
+		has := func(bit int) bool { return scalars&(1<<bit) != 0 }
+		if has(scalarGameTime) {
+			diff.GameTime = int(r.varint())
+		}
+		if has(scalarPhase) {
+			diff.Phase = r.string()
+		}
+		if has(scalarPhaseEndTick) {
+			diff.PhaseEndTick = int(r.varint())
+		}
+		if has(scalarMatchID) {
+			diff.MatchID = int(r.varint())
+		}
+		if has(scalarWinner) {
+			diff.Winner = r.id()
+		}
+		if has(scalarLastProcessedInput) {
+			diff.LastProcessedInput = int(r.varint())
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.pos != len(r.buf) {
+		return nil, errors.New("trailing bytes")
+	}
+	return diff, nil
+}
+
+func encodeWorldChunks(chunks []*WorldChunk) []byte {
+	w := &binaryWriter{buf: make([]byte, 0, 1024)}
+	w.byte(BINARY_MSG_WORLD_CHUNKS)
+	w.count(len(chunks))
+	for _, chunk := range chunks {
+		w.varint(int64(chunk.ChunkX))
+		w.varint(int64(chunk.ChunkY))
+		w.count(len(chunk.Buildings))
+		for _, building := range chunk.Buildings {
+			w.coord(building.X)
+			w.coord(building.Y)
+			w.coord(building.Width)
+			w.coord(building.Height)
+		}
+		w.count(len(chunk.Trees))
+		for _, tree := range chunk.Trees {
+			w.coord(tree.X)
+			w.coord(tree.Y)
+			w.coord(tree.Size)
+			w.string(tree.Type)
+		}
+	}
+	return w.buf
+}
+
+func decodeWorldChunks(data []byte) ([]*WorldChunk, error) {
+	r := &binaryReader{buf: data}
+	if r.byte() != BINARY_MSG_WORLD_CHUNKS {
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, errors.New("unexpected binary message type")
+	}
+	chunks := make([]*WorldChunk, r.count())
+	for i := range chunks {
+		chunk := &WorldChunk{ChunkX: int(r.varint()), ChunkY: int(r.varint())}
+		chunk.Buildings = make([]Building, r.count())
+		for j := range chunk.Buildings {
+			chunk.Buildings[j] = Building{X: r.coord(), Y: r.coord(), Width: r.coord(), Height: r.coord()}
+		}
+		chunk.Trees = make([]Tree, r.count())
+		for j := range chunk.Trees {
+			chunk.Trees[j] = Tree{X: r.coord(), Y: r.coord(), Size: r.coord(), Type: r.string()}
+		}
+		chunks[i] = chunk
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return chunks, nil
+}
+
+// Input fields, in the order they follow the input mask. Shoot carries no
+// payload; its bit is the value.
+const (
+	inputMoveX = iota
+	inputMoveY
+	inputAngle
+	inputShoot
+	inputClientX
+	inputClientY
+	inputID
+	inputTick
+)
+
+// encodeInput packs an input message the way the client does.
+func encodeInput(msg *InputMessage) []byte {
+	w := &binaryWriter{}
+	w.byte(BINARY_MSG_INPUT)
+
+	var mask uint64
+	set := func(bit int, present bool) {
+		if present {
+			mask |= 1 << bit
+		}
+	}
+	set(inputMoveX, msg.MoveX != 0)
+	set(inputMoveY, msg.MoveY != 0)
+	set(inputAngle, msg.Angle != 0)
+	set(inputShoot, msg.Shoot)
+	set(inputClientX, msg.ClientX != 0)
+	set(inputClientY, msg.ClientY != 0)
+	set(inputID, msg.InputID != 0)
+	set(inputTick, msg.Tick != 0)
+	w.uvarint(mask)
+
+	if msg.MoveX != 0 {
+		w.coord(msg.MoveX)
+	}
+	if msg.MoveY != 0 {
+		w.coord(msg.MoveY)
+	}
+	if msg.Angle != 0 {
+		w.angle(msg.Angle)
+	}
+	if msg.ClientX != 0 {
+		w.coord(msg.ClientX)
+	}
+	if msg.ClientY != 0 {
+		w.coord(msg.ClientY)
+	}
+	if msg.InputID != 0 {
+		w.uvarint(uint64(msg.InputID))
+	}
+	if msg.Tick != 0 {
+		w.uvarint(uint64(msg.Tick))
+	}
+	return w.buf
+}
+
+func decodeInputMessage(data []byte, msg *InputMessage) error {
+	r := &binaryReader{buf: data}
+	if r.byte() != BINARY_MSG_INPUT {
+		if r.err != nil {
+			return r.err
+		}
+		return errors.New("unexpected binary message type")
+	}
+
+	mask := r.uvarint()
+	has := func(bit int) bool { return mask&(1<<bit) != 0 }
+	msg.Type = "input"
+	if has(inputMoveX) {
+		msg.MoveX = r.coord()
+	}
+	if has(inputMoveY) {
+		msg.MoveY = r.coord()
+	}
+	if has(inputAngle) {
+		msg.Angle = r.angle()
+	}
+	msg.Shoot = has(inputShoot)
+	if has(inputClientX) {
+		msg.ClientX = r.coord()
+	}
+	if has(inputClientY) {
+		msg.ClientY = r.coord()
+	}
+	if has(inputID) {
+		msg.InputID = int(r.uvarint())
+	}
+	if has(inputTick) {
+		msg.Tick = int(r.uvarint())
+	}
+	return r.err
+}
+
+// readInputMessage parses a client frame, binary or JSON.
+func readInputMessage(messageType int, data []byte, msg *InputMessage) error {
+	if messageType == websocket.BinaryMessage {
+		return decodeInputMessage(data, msg)
+	}
+	return json.Unmarshal(data, msg)
+}
+
+// encodeStateDiff serializes diff in the client's negotiated encoding.
+// Only the client's writer goroutine may call it, so encoder state follows
+// write order.
+func (c *clientConn) encodeStateDiff(diff *StateDiff) (int, []byte, error) {
+	if c.encoder != nil {
+		return websocket.BinaryMessage, c.encoder.encodeStateDiff(diff), nil
+	}
+	data, err := json.Marshal(diff)
+	return websocket.TextMessage, data, err
+}
+
+func (c *clientConn) encodeWorldChunks(chunks []*WorldChunk) (int, []byte, error) {
+	if c.encoder != nil {
+		return websocket.BinaryMessage, encodeWorldChunks(chunks), nil
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"type":   "worldChunks",
+		"chunks": chunks,
+	})
+	return websocket.TextMessage, data, err
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// Angles are quantized to 1/65536 of a turn, and π and -π are the same
+// direction.
+func angleClose(a, b float64) bool {
+	d := math.Mod(math.Abs(a-b), 2*math.Pi)
+	return math.Min(d, 2*math.Pi-d) < 2*math.Pi/65536
+}
+
+func TestStateDiffRoundTrip(t *testing.T) {
+	bigID := "player_1792194470409045762" // above 2^53, sent as a string
+	player := &Player{
+		ID: bigID, X: -0.01, Y: -123456.78, Angle: -math.Pi, Health: 100, Alive: true,
+		Velocity: 25, Ammo: 0, Magazine: 30, Weapon: "rifle", Score: -5, Kills: 3,
+		Reloading: true, ReloadProgress: 0.5, VestTier: 2, VestDurability: 150,
+		HelmetTier: 1, HelmetDurability: 80, Healing: "bandage", HealProgress: 1,
+		Boost: 42, IsBot: false,
+	}
+	bot := &Player{ID: "enemy_9007199254740991", X: 0, Y: 0, Alive: true, IsBot: true}
+	diff := &StateDiff{
+		Type:    "stateDiff",
+		Tick:    1 << 40,
+		Players: map[string]*Player{bigID: player, bot.ID: bot},
+		Bullets: map[string]*Bullet{"bullet_1": {
+			ID: "bullet_1", PlayerID: bot.ID, X: -1, Y: 0, Angle: 1.5, Speed: 1000, Active: true, Weapon: "pistol",
+		}},
+		AmmoPickups:    map[string]*AmmoPickup{"ammo_0": {ID: "ammo_0", X: 0, Y: -0.5, Amount: 75, Active: true}},
+		WeaponPickups:  map[string]*WeaponPickup{"weapon_7": {ID: "weapon_7", X: 10, Y: 20, Weapon: "shotgun"}},
+		HealthPickups:  map[string]*HealthPickup{"health_3": {ID: "health_3", X: -5, Y: 5, Item: "medkit", Amount: 1, Active: true}},
+		ArmorPickups:   map[string]*ArmorPickup{"armor_01": {ID: "armor_01", X: 1, Y: 1, Kind: "vest", Tier: 3, Durability: 0, Active: true}},
+		RemovedPlayers: []string{"player_5"},
+		RemovedBullets: []string{"bullet_2", "custom-id"},
+		RemovedAmmo:    []string{"ammo_3"},
+		RemovedWeapons: []string{"weapon_4"},
+		RemovedHealth:  []string{"health_5"},
+		RemovedArmor:   []string{"armor_6"},
+		Zone: &ZoneDiff{
+			CenterX: 0, CenterY: 0, Radius: 3200, NextX: -543.86, NextY: 0, NextRadius: 2000,
+			Stage: 0, State: "waiting", StateEndTick: 1507,
+		},
+		GameTime:           -1,
+		Phase:              "playing",
+		PhaseEndTick:       0,
+		MatchID:            7,
+		Winner:             bigID,
+		Inventory:          &Inventory{Slots: []InventorySlot{{Weapon: "rifle", Magazine: 30}, {}}, Active: 1, Consumables: map[string]int{}},
+		LastProcessedInput: 1 << 31,
+		Impacts:            []BulletImpact{{BulletID: "bullet_1", X: -3, Y: 4, Target: "building"}},
+	}
+
+	enc := newBinaryEncoder()
+	dec := newBinaryDecoder()
+	got, err := dec.decodeStateDiff(enc.encodeStateDiff(diff))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if !angleClose(got.Players[bigID].Angle, player.Angle) {
+		t.Errorf("angle %v, want %v", got.Players[bigID].Angle, player.Angle)
+	}
+	got.Players[bigID].Angle = player.Angle
+	if !angleClose(got.Bullets["bullet_1"].Angle, 1.5) {
+		t.Errorf("bullet angle %v, want 1.5", got.Bullets["bullet_1"].Angle)
+	}
+	got.Bullets["bullet_1"].Angle = 1.5
+	if !reflect.DeepEqual(got, diff) {
+		t.Errorf("round trip mismatch\n got %+v\nwant %+v", got, diff)
+	}
+
+	// Only changed fields follow, including ones that went back to zero.
+	moved := *bot
+	moved.X, moved.Y, moved.Alive = -10, 0, false
+	next := &StateDiff{
+		Type:    "stateDiff",
+		Tick:    2,
+		Players: map[string]*Player{bot.ID: &moved},
+		Zone:    &ZoneDiff{},
+	}
+	got, err = dec.decodeStateDiff(enc.encodeStateDiff(next))
+	if err != nil {
+		t.Fatalf("decode second diff: %v", err)
+	}
+	if !reflect.DeepEqual(got, next) {
+		t.Errorf("second diff mismatch\n got %+v\nwant %+v", got, next)
+	}
+}
+
+func TestStateDiffEmpty(t *testing.T) {
+	diff := &StateDiff{
+		Type:          "stateDiff",
+		Players:       map[string]*Player{},
+		Bullets:       map[string]*Bullet{},
+		AmmoPickups:   map[string]*AmmoPickup{},
+		WeaponPickups: map[string]*WeaponPickup{},
+		HealthPickups: map[string]*HealthPickup{},
+		ArmorPickups:  map[string]*ArmorPickup{},
+	}
+	data := newBinaryEncoder().encodeStateDiff(diff)
+	if want := []byte{BINARY_MSG_STATE_DIFF, 0, 0}; !reflect.DeepEqual(data, want) {
+		t.Fatalf("encoded %v, want %v", data, want)
+	}
+	got, err := newBinaryDecoder().decodeStateDiff(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !reflect.DeepEqual(got, &StateDiff{Type: "stateDiff"}) {
+		t.Errorf("got %+v, want an empty diff", got)
+	}
+}
+
+func TestWorldChunksRoundTrip(t *testing.T) {
+	chunks := []*WorldChunk{
+		{
+			ChunkX:    -1,
+			ChunkY:    0,
+			Buildings: []Building{{X: -2000, Y: 0, Width: 120.5, Height: 80}},
+			Trees:     []Tree{{X: -0.01, Y: -999.99, Size: 30, Type: "pine"}, {X: 0, Y: 0, Size: 0, Type: ""}},
+		},
+		{ChunkX: math.MaxInt32, ChunkY: math.MinInt32, Buildings: []Building{}, Trees: []Tree{}},
+	}
+	got, err := decodeWorldChunks(encodeWorldChunks(chunks))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !reflect.DeepEqual(got, chunks) {
+		t.Errorf("round trip mismatch\n got %+v\nwant %+v", got, chunks)
+	}
+
+	got, err = decodeWorldChunks(encodeWorldChunks(nil))
+	if err != nil || len(got) != 0 {
+		t.Errorf("empty chunk list decoded to %v, %v", got, err)
+	}
+}
+
+func TestInputRoundTrip(t *testing.T) {
+	tests := []InputMessage{
+		{Type: "input"},
+		{Type: "input", MoveX: -1, MoveY: 0.71, Angle: 3, Shoot: true, ClientX: -4321.5, ClientY: 0.01, InputID: 1 << 40, Tick: 1},
+		{Type: "input", Shoot: true},
+		{Type: "input", ClientX: -0.01, InputID: 1},
+	}
+	for _, want := range tests {
+		var got InputMessage
+		if err := decodeInputMessage(encodeInput(&want), &got); err != nil {
+			t.Fatalf("decode %+v: %v", want, err)
+		}
+		if !angleClose(got.Angle, want.Angle) {
+			t.Errorf("angle %v, want %v", got.Angle, want.Angle)
+		}
+		got.Angle = want.Angle
+		if got != want {
+			t.Errorf("round trip mismatch\n got %+v\nwant %+v", got, want)
+		}
+	}
+}
+
+func TestTruncatedFramesRejected(t *testing.T) {
+	input := encodeInput(&InputMessage{MoveX: 1, MoveY: -1, Angle: 1, Shoot: true, ClientX: 5, ClientY: -5, InputID: 300, Tick: 70000})
+	for n := 0; n < len(input); n++ {
+		var msg InputMessage
+		if err := decodeInputMessage(input[:n], &msg); err == nil {
+			t.Errorf("input truncated to %d of %d bytes was accepted", n, len(input))
+		}
+	}
+
+	diff := &StateDiff{
+		Tick:     5,
+		Players:  map[string]*Player{"player_1": {ID: "player_1", X: 1, Weapon: "rifle", Alive: true}},
+		Zone:     &ZoneDiff{Radius: 100, State: "final"},
+		Phase:    "playing",
+		Winner:   "player_1",
+		Bullets:  map[string]*Bullet{"bullet_1": {ID: "bullet_1", Weapon: "pistol"}},
+		Impacts:  []BulletImpact{{BulletID: "bullet_1", Target: "tree"}},
+		GameTime: 10,
+	}
+	frame := newBinaryEncoder().encodeStateDiff(diff)
+	for n := 0; n < len(frame); n++ {
+		if _, err := newBinaryDecoder().decodeStateDiff(frame[:n]); err == nil {
+			t.Errorf("state diff truncated to %d of %d bytes was accepted", n, len(frame))
+		}
+	}
+
+	chunks := encodeWorldChunks([]*WorldChunk{{ChunkX: 1, Trees: []Tree{{Type: "oak"}}}})
+	for n := 0; n < len(chunks); n++ {
+		if _, err := decodeWorldChunks(chunks[:n]); err == nil {
+			t.Errorf("world chunks truncated to %d of %d bytes were accepted", n, len(chunks))
+		}
+	}
+}
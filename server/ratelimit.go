@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log"
+
+	"github.com/gorilla/websocket"
+)
+
+// InputRate tracks how many movement inputs a player sent over the last
+// second of ticks, and how often they went over the limit.
+type InputRate struct {
+	WindowStart int
+	Count       int
+	Dropped     int
+	Violations  int
+}
+
+// allowMovementInput counts a movement input from the player and reports
+// whether it should be queued. Callers must hold inputQueueMu.
+func (gs *GameServer) allowMovementInput(playerID string, tick int) bool {
+	rate := gs.inputRates[playerID]
+	if rate == nil {
+		rate = &InputRate{WindowStart: tick}
+		gs.inputRates[playerID] = rate
+	}
+
+	if tick-rate.WindowStart >= TICK_RATE {
+		if rate.Count > MAX_MOVE_INPUTS_PER_SECOND {
+			gs.flagInputViolation(playerID, rate, "sent %d movement inputs in one second (%d dropped)", rate.Count, rate.Dropped)
+		}
+		rate.WindowStart = tick
+		rate.Count = 0
+		rate.Dropped = 0
+	}
+	rate.Count++
+
+	if len(gs.inputQueue[playerID]) >= MAX_QUEUED_INPUTS {
+		rate.Dropped++
+		return false
+	}
+	return true
+}
+
+// flagInputViolation records a violation and disconnects the player once
+// they reach MAX_INPUT_VIOLATIONS. Callers must hold inputQueueMu.
+func (gs *GameServer) flagInputViolation(playerID string, rate *InputRate, format string, args ...interface{}) {
+	rate.Violations++
+	gs.inputViolations++
+	log.Printf("[ANTICHEAT] Player %s "+format, append([]interface{}{playerID}, args...)...)
+	if rate.Violations < MAX_INPUT_VIOLATIONS {
+		return
+	}
+
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	for _, client := range gs.clients {
+		if client.player.ID == playerID {
+			client.close(websocket.ClosePolicyViolation, "too many inputs")
+		}
+	}
+	gs.inputKicks++
+	log.Printf("[ANTICHEAT] Player %s disconnected after %d input violations", playerID, rate.Violations)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// A room is one independent match with its own GameServer and game loop.
+// Rooms are opened by the matchmaker or as private lobbies; a player can also
+// ask for a specific public room that is still in its lobby or warmup and has
+// space. Rooms without players are closed after ROOM_EMPTY_TIMEOUT.
+const (
+	ROOM_MAX_PLAYERS      = 16
+	ROOM_EMPTY_TIMEOUT    = 30 * time.Second
+	ROOM_CLEANUP_INTERVAL = 10 * time.Second
+)
+
+type Room struct {
+	ID         string
+	server     *GameServer
+	emptySince time.Time
+}
+
+// RoomStats is one entry of the /metrics response.
+type RoomStats struct {
+	ID              string      `json:"id"`
+	Players         int         `json:"players"`
+	Phase           string      `json:"phase"`
+	MatchID         int         `json:"matchId"`
+	InputViolations int         `json:"inputViolations"`
+	InputKicks      int         `json:"inputKicks"`
+	Loop            LoopMetrics `json:"loop"`
+}
+
+type RoomManager struct {
+	mu         sync.Mutex
+	rooms      map[string]*Room
+	lobbies    map[string]*Room
+	nextRoomID int
+	upgrader   websocket.Upgrader
+	matchmaker *Matchmaker
+}
+
+func NewRoomManager() *RoomManager {
+	rm := &RoomManager{
+		rooms:      make(map[string]*Room),
+		lobbies:    make(map[string]*Room),
+		nextRoomID: 1,
+		upgrader: websocket.Upgrader{
+			EnableCompression: true,
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		},
+	}
+	rm.matchmaker = newMatchmaker(rm)
+	return rm
+}
+
+// createRoom starts a new room playing by rules and its game loop. lobby is
+// nil for public rooms. Callers must hold rm.mu.
+func (rm *RoomManager) createRoom(rules MatchRules, lobby *Lobby) *Room {
+	id := fmt.Sprintf("room_%d", rm.nextRoomID)
+	rm.nextRoomID++
+
+	room := &Room{
+		ID:         id,
+		server:     NewGameServer(id, rules),
+		emptySince: time.Now(),
+	}
+	room.server.lobby = lobby
+	rm.rooms[id] = room
+	go room.server.startGameLoop()
+
+	log.Printf("[ROOM %s] Created (%d rooms open)", id, len(rm.rooms))
+	return room
+}
+
+// joinable reports whether a new player may enter the room without an
+// invite code.
+func (room *Room) joinable() bool {
+	gs := room.server
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	if gs.lobby != nil || gs.occupancy() >= ROOM_MAX_PLAYERS {
+		return false
+	}
+	return gs.gameState.Phase == PHASE_LOBBY || gs.gameState.Phase == PHASE_WARMUP
+}
+
+// occupancy counts the room's clients plus the joins that were assigned to
+// it but not accepted yet. Callers must hold gs.mu.
+func (gs *GameServer) occupancy() int {
+	return len(gs.clients) + gs.pendingJoins
+}
+
+// reserveSlot holds a place in the room for a join until releaseSlot, so
+// the join can finish without rm.mu. Callers must hold rm.mu.
+func (room *Room) reserveSlot() {
+	room.server.mu.Lock()
+	room.server.pendingJoins++
+	room.server.mu.Unlock()
+}
+
+func (room *Room) releaseSlot() {
+	room.server.mu.Lock()
+	room.server.pendingJoins--
+	room.server.mu.Unlock()
+}
+
+// assignRoom picks the room for a connection that should skip matchmaking.
+// A known session always goes back to its room; otherwise the requested room
+// is used if it can take the player. Returns nil when the player should be
+// queued. Callers must hold rm.mu.
+func (rm *RoomManager) assignRoom(requested, sessionID string) *Room {
+	if sessionID != "" {
+		for _, room := range rm.rooms {
+			if room.server.hasSession(sessionID) {
+				return room
+			}
+		}
+	}
+
+	if room := rm.rooms[requested]; room != nil {
+		if room.joinable() {
+			return room
+		}
+		log.Printf("[ROOM %s] Full or in progress, queueing player instead", room.ID)
+	}
+	return nil
+}
+
+func (rm *RoomManager) handleConnection(w http.ResponseWriter, r *http.Request) {
+	conn, err := rm.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("Upgrade error:", err)
+		return
+	}
+
+	caps, err := readHello(conn, r)
+	if err != nil {
+		if err != errHandshakeRejected {
+			log.Printf("[HANDSHAKE] No hello from %s: %v", conn.RemoteAddr(), err)
+			conn.Close()
+		}
+		return
+	}
+
+	sessionID := r.URL.Query().Get("session")
+	lobbyCode := r.URL.Query().Get("lobby")
+
+	// The room is picked and a slot reserved under rm.mu, so concurrent
+	// joins can't overfill it and cleanup won't close it before the player
+	// is accepted.
+	rm.mu.Lock()
+	var room *Room
+	if lobbyCode != "" {
+		var closeCode int
+		var reason string
+		room, closeCode, reason = rm.admitToLobby(lobbyCode, sessionID)
+		if room == nil {
+			rm.mu.Unlock()
+			rejectConnection(conn, closeCode, reason)
+			return
+		}
+	} else {
+		room = rm.assignRoom(r.URL.Query().Get("room"), sessionID)
+	}
+	if room != nil {
+		room.reserveSlot()
+	}
+	rm.mu.Unlock()
+
+	if room == nil {
+		rm.matchmaker.wait(conn, sessionID, caps)
+		return
+	}
+	player := room.server.acceptClient(conn, sessionID, caps)
+	room.releaseSlot()
+	room.server.handleClient(conn, player)
+}
+
+// cleanupRooms periodically shuts down rooms that have had no players for
+// ROOM_EMPTY_TIMEOUT.
+func (rm *RoomManager) cleanupRooms() {
+	ticker := time.NewTicker(ROOM_CLEANUP_INTERVAL)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		rm.mu.Lock()
+		for id, room := range rm.rooms {
+			lobby := room.server.lobby
+			room.server.mu.RLock()
+			players := room.server.occupancy()
+			joined := lobby != nil && lobby.joined
+			room.server.mu.RUnlock()
+
+			if players > 0 {
+				room.emptySince = time.Time{}
+				continue
+			}
+			if room.emptySince.IsZero() {
+				room.emptySince = now
+				continue
+			}
+			timeout := ROOM_EMPTY_TIMEOUT
+			if lobby != nil {
+				timeout = LOBBY_EMPTY_TIMEOUT
+				if !joined {
+					timeout = LOBBY_UNJOINED_TIMEOUT
+				}
+			}
+			if now.Sub(room.emptySince) >= timeout {
+				room.server.shutdown()
+				delete(rm.rooms, id)
+				if lobby != nil {
+					delete(rm.lobbies, lobby.Code)
+				}
+				log.Printf("[ROOM %s] Closed after being empty for %s (%d rooms open)", id, timeout, len(rm.rooms))
+			}
+		}
+		rm.mu.Unlock()
+	}
+}
+
+func (rm *RoomManager) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	rm.mu.Lock()
+	stats := make([]RoomStats, 0, len(rm.rooms))
+	for id, room := range rm.rooms {
+		gs := room.server
+		gs.mu.RLock()
+		stats = append(stats, RoomStats{
+			ID:      id,
+			Players: len(gs.clients),
+			Phase:   gs.gameState.Phase,
+			MatchID: gs.gameState.MatchID,
+		})
+		gs.mu.RUnlock()
+		gs.inputQueueMu.Lock()
+		stats[len(stats)-1].InputViolations = gs.inputViolations
+		stats[len(stats)-1].InputKicks = gs.inputKicks
+		gs.inputQueueMu.Unlock()
+		stats[len(stats)-1].Loop = gs.metrics.snapshot()
+	}
+	rm.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -11,8 +11,6 @@ const (
 	BROADCAST_RATE            = 20
 	CHUNK_SIZE                = 500.0
 	ZONE_INITIAL_SIZE         = 3200
-	ZONE_SHRINK_RATE          = 0.5
-	ZONE_RESET_INTERVAL       = 100
 	COORD_EPSILON             = 0.01
 	ANGLE_EPSILON             = 0.0001
 	CLIENT_POS_TOLERANCE      = 15.0
@@ -21,9 +19,60 @@ const (
 	PLAYER_UPDATE_DISTANCE    = 3000.0
 	PLAYER_UPDATE_DISTANCE_SQ = PLAYER_UPDATE_DISTANCE * PLAYER_UPDATE_DISTANCE
 	SPATIAL_GRID_CELL_SIZE    = 500.0
+	INTEREST_GRID_CELL_SIZE   = 1000.0
 	PLAYER_RADIUS             = 8.0
 	BULLET_HIT_RADIUS         = 15.0
 	PICKUP_RADIUS             = 20.0
+	MATCH_MIN_PLAYERS         = 1
+	LOBBY_COUNTDOWN_TICKS     = 10 * TICK_RATE
+	WARMUP_TICKS              = 15 * TICK_RATE
+	RESULTS_TICKS             = 10 * TICK_RATE
+	FINAL_ZONE_TICKS          = 60 * TICK_RATE
+	INVENTORY_WEAPON_SLOTS    = 2
+	LOOT_SCATTER_RADIUS       = 18.0
+	HEADSHOT_CHANCE           = 0.2
+	HEADSHOT_MULTIPLIER       = 1.5
+	MAX_CATCHUP_TICKS         = 5
+	METRICS_LOG_INTERVAL      = 10 * TICK_RATE
+	MAX_HEALTH                = 1000
+	BANDAGE_HEAL_CAP          = 750
+	BOOST_MAX                 = 100.0
+	BOOST_DECAY_PER_TICK      = BOOST_MAX / (90 * TICK_RATE)
+	BOOST_REGEN_INTERVAL      = TICK_RATE / 4
+	LAG_COMP_HISTORY_TICKS    = TICK_RATE
+	LAG_COMP_MAX_REWIND_TICKS = 5
+)
+
+const (
+	MAX_QUEUED_INPUTS          = 4
+	MAX_QUEUED_SHOTS           = 4
+	MAX_MOVE_INPUTS_PER_SECOND = TICK_RATE * 3 / 2
+	MAX_INPUT_VIOLATIONS       = 5
+)
+
+const (
+	PHASE_LOBBY    = "lobby"
+	PHASE_WARMUP   = "warmup"
+	PHASE_PLAYING  = "playing"
+	PHASE_FINISHED = "finished"
+)
+
+const (
+	ARMOR_VEST   = "vest"
+	ARMOR_HELMET = "helmet"
+)
+
+const (
+	ITEM_BANDAGE = "bandage"
+	ITEM_MEDKIT  = "medkit"
+	ITEM_BOOST   = "boost"
+)
+
+const (
+	ZONE_STATE_IDLE      = "idle"
+	ZONE_STATE_WAITING   = "waiting"
+	ZONE_STATE_SHRINKING = "shrinking"
+	ZONE_STATE_FINAL     = "final"
 )
 
 type Player struct {
@@ -35,10 +84,41 @@ type Player struct {
 	Alive     bool    `json:"alive"`
 	Velocity  float64 `json:"velocity"`
 	Ammo      int     `json:"ammo"`
+	Magazine  int     `json:"magazine"`
 	Weapon    string  `json:"weapon"`
 	Score     int     `json:"score"`
 	Kills     int     `json:"kills"`
-	LastShoot int64   `json:"-"`
+	LastShoot int     `json:"-"`
+	IsBot     bool    `json:"isBot,omitempty"`
+
+	Reloading      bool    `json:"reloading,omitempty"`
+	ReloadProgress float64 `json:"reloadProgress,omitempty"`
+	ReloadStart    int     `json:"-"`
+	ReloadEnd      int     `json:"-"`
+
+	VestTier         int `json:"vestTier,omitempty"`
+	VestDurability   int `json:"vestDurability,omitempty"`
+	HelmetTier       int `json:"helmetTier,omitempty"`
+	HelmetDurability int `json:"helmetDurability,omitempty"`
+
+	Healing      string  `json:"healing,omitempty"`
+	HealProgress float64 `json:"healProgress,omitempty"`
+	HealStart    int     `json:"-"`
+	HealEnd      int     `json:"-"`
+	Boost        float64 `json:"boost,omitempty"`
+
+	Inventory *Inventory `json:"-"`
+}
+
+type InventorySlot struct {
+	Weapon   string `json:"weapon"`
+	Magazine int    `json:"magazine"`
+}
+
+type Inventory struct {
+	Slots       []InventorySlot `json:"slots"`
+	Active      int             `json:"active"`
+	Consumables map[string]int  `json:"consumables"`
 }
 
 type Bullet struct {
@@ -50,6 +130,20 @@ type Bullet struct {
 	Speed    float64 `json:"speed"`
 	Active   bool    `json:"active"`
 	Weapon   string  `json:"weapon"`
+	Damage   int     `json:"-"`
+	Range    float64 `json:"-"`
+	Traveled float64 `json:"-"`
+	Falloff  Falloff `json:"-"`
+	Rewind   int     `json:"-"`
+}
+
+// BulletImpact records where a bullet stopped and what it hit.
+type BulletImpact struct {
+	BulletID string  `json:"bulletId"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Target   string  `json:"target"`
+	PlayerID string  `json:"playerId,omitempty"`
 }
 
 type AmmoPickup struct {
@@ -66,14 +160,30 @@ type WeaponPickup struct {
 	Y      float64 `json:"y"`
 	Weapon string  `json:"weapon"`
 	Active bool    `json:"active"`
+
+	Ammo      int    `json:"-"`
+	DroppedBy string `json:"-"`
 }
 
 type HealthPickup struct {
 	ID     string  `json:"id"`
 	X      float64 `json:"x"`
 	Y      float64 `json:"y"`
+	Item   string  `json:"item"`
 	Amount int     `json:"amount"`
 	Active bool    `json:"active"`
+
+	DroppedBy string `json:"-"`
+}
+
+type ArmorPickup struct {
+	ID         string  `json:"id"`
+	X          float64 `json:"x"`
+	Y          float64 `json:"y"`
+	Kind       string  `json:"kind"`
+	Tier       int     `json:"tier"`
+	Durability int     `json:"durability"`
+	Active     bool    `json:"active"`
 }
 
 type Building struct {
@@ -91,42 +201,74 @@ type Tree struct {
 }
 
 type GameState struct {
-	Players       map[string]*Player       `json:"players"`
-	Bullets       map[string]*Bullet       `json:"bullets"`
-	AmmoPickups   map[string]*AmmoPickup   `json:"ammoPickups"`
-	WeaponPickups map[string]*WeaponPickup `json:"weaponPickups"`
-	HealthPickups map[string]*HealthPickup `json:"healthPickups"`
-	Buildings     []Building               `json:"buildings"`
-	Trees         []Tree                   `json:"trees"`
-	ZoneCenter    float64                  `json:"zoneCenter"`
-	ZoneRadius    float64                  `json:"zoneRadius"`
-	GameTime      int                      `json:"gameTime"`
-	Phase         string                   `json:"phase"`
-	Winner        string                   `json:"winner,omitempty"`
+	Players          map[string]*Player       `json:"players"`
+	Bullets          map[string]*Bullet       `json:"bullets"`
+	AmmoPickups      map[string]*AmmoPickup   `json:"ammoPickups"`
+	WeaponPickups    map[string]*WeaponPickup `json:"weaponPickups"`
+	HealthPickups    map[string]*HealthPickup `json:"healthPickups"`
+	ArmorPickups     map[string]*ArmorPickup  `json:"armorPickups"`
+	Buildings        []Building               `json:"buildings"`
+	Trees            []Tree                   `json:"trees"`
+	ZoneCenterX      float64                  `json:"zoneCenterX"`
+	ZoneCenterY      float64                  `json:"zoneCenterY"`
+	ZoneRadius       float64                  `json:"zoneRadius"`
+	NextZoneX        float64                  `json:"nextZoneX"`
+	NextZoneY        float64                  `json:"nextZoneY"`
+	NextZoneRadius   float64                  `json:"nextZoneRadius"`
+	ZoneStage        int                      `json:"zoneStage"`
+	ZoneState        string                   `json:"zoneState"`
+	ZoneStateEndTick int                      `json:"zoneStateEndTick"`
+	GameTime         int                      `json:"gameTime"`
+	Phase            string                   `json:"phase"`
+	PhaseEndTick     int                      `json:"phaseEndTick,omitempty"`
+	MatchID          int                      `json:"matchId"`
+	Winner           string                   `json:"winner,omitempty"`
 }
 
 type DynamicState struct {
-	Players       map[string]*Player       `json:"players"`
-	Bullets       map[string]*Bullet       `json:"bullets"`
-	AmmoPickups   map[string]*AmmoPickup   `json:"ammoPickups"`
-	WeaponPickups map[string]*WeaponPickup `json:"weaponPickups"`
-	HealthPickups map[string]*HealthPickup `json:"healthPickups"`
-	Buildings     []Building               `json:"buildings"`
-	Trees         []Tree                   `json:"trees"`
-	ZoneCenter    float64                  `json:"zoneCenter"`
-	ZoneRadius    float64                  `json:"zoneRadius"`
-	GameTime      int                      `json:"gameTime"`
-	Phase         string                   `json:"phase"`
-	Winner        string                   `json:"winner,omitempty"`
+	Players          map[string]*Player       `json:"players"`
+	Bullets          map[string]*Bullet       `json:"bullets"`
+	AmmoPickups      map[string]*AmmoPickup   `json:"ammoPickups"`
+	WeaponPickups    map[string]*WeaponPickup `json:"weaponPickups"`
+	HealthPickups    map[string]*HealthPickup `json:"healthPickups"`
+	ArmorPickups     map[string]*ArmorPickup  `json:"armorPickups"`
+	ZoneCenterX      float64                  `json:"zoneCenterX"`
+	ZoneCenterY      float64                  `json:"zoneCenterY"`
+	ZoneRadius       float64                  `json:"zoneRadius"`
+	NextZoneX        float64                  `json:"nextZoneX"`
+	NextZoneY        float64                  `json:"nextZoneY"`
+	NextZoneRadius   float64                  `json:"nextZoneRadius"`
+	ZoneStage        int                      `json:"zoneStage"`
+	ZoneState        string                   `json:"zoneState"`
+	ZoneStateEndTick int                      `json:"zoneStateEndTick"`
+	GameTime         int                      `json:"gameTime"`
+	Phase            string                   `json:"phase"`
+	PhaseEndTick     int                      `json:"phaseEndTick,omitempty"`
+	MatchID          int                      `json:"matchId"`
+	Winner           string                   `json:"winner,omitempty"`
+	Inventories      map[string]*Inventory    `json:"-"`
+	Impacts          []BulletImpact           `json:"-"`
+
+	grid *SpatialGrid
 }
 
 type clientConn struct {
 	conn        *websocket.Conn
 	player      *Player
-	writeMu     sync.Mutex
+	sessionID   string
 	knownChunks map[string]bool
 	lastState   *DynamicState
 	lastStateMu sync.RWMutex
+	caps        Capabilities
+	encoder     *binaryEncoder
+
+	send       chan outboundMessage
+	stateReady chan struct{}
+	pendingMu  sync.Mutex
+	pending    *DynamicState
+	behind     int
+	done       chan struct{}
+	closeOnce  sync.Once
 }
 
 type WorldChunk struct {
@@ -137,50 +279,98 @@ type WorldChunk struct {
 }
 
 type StateDiff struct {
-	Type           string                   `json:"type"`
-	Tick           int                      `json:"tick"`
-	Players        map[string]*Player       `json:"players,omitempty"`
-	Bullets        map[string]*Bullet       `json:"bullets,omitempty"`
-	AmmoPickups    map[string]*AmmoPickup   `json:"ammoPickups,omitempty"`
-	WeaponPickups  map[string]*WeaponPickup `json:"weaponPickups,omitempty"`
-	HealthPickups  map[string]*HealthPickup `json:"healthPickups,omitempty"`
-	RemovedPlayers []string                 `json:"removedPlayers,omitempty"`
-	RemovedBullets []string                 `json:"removedBullets,omitempty"`
-	RemovedAmmo    []string                 `json:"removedAmmo,omitempty"`
-	RemovedWeapons []string                 `json:"removedWeapons,omitempty"`
-	RemovedHealth  []string                 `json:"removedHealth,omitempty"`
-	ZoneCenter     float64                  `json:"zoneCenter,omitempty"`
-	ZoneRadius     float64                  `json:"zoneRadius,omitempty"`
-	GameTime       int                      `json:"gameTime,omitempty"`
-	Phase          string                   `json:"phase,omitempty"`
-	Winner         string                   `json:"winner,omitempty"`
+	Type               string                   `json:"type"`
+	Tick               int                      `json:"tick"`
+	Players            map[string]*Player       `json:"players,omitempty"`
+	Bullets            map[string]*Bullet       `json:"bullets,omitempty"`
+	AmmoPickups        map[string]*AmmoPickup   `json:"ammoPickups,omitempty"`
+	WeaponPickups      map[string]*WeaponPickup `json:"weaponPickups,omitempty"`
+	HealthPickups      map[string]*HealthPickup `json:"healthPickups,omitempty"`
+	ArmorPickups       map[string]*ArmorPickup  `json:"armorPickups,omitempty"`
+	RemovedPlayers     []string                 `json:"removedPlayers,omitempty"`
+	RemovedBullets     []string                 `json:"removedBullets,omitempty"`
+	RemovedAmmo        []string                 `json:"removedAmmo,omitempty"`
+	RemovedWeapons     []string                 `json:"removedWeapons,omitempty"`
+	RemovedHealth      []string                 `json:"removedHealth,omitempty"`
+	RemovedArmor       []string                 `json:"removedArmor,omitempty"`
+	Zone               *ZoneDiff                `json:"zone,omitempty"`
+	GameTime           int                      `json:"gameTime,omitempty"`
+	Phase              string                   `json:"phase,omitempty"`
+	PhaseEndTick       int                      `json:"phaseEndTick,omitempty"`
+	MatchID            int                      `json:"matchId,omitempty"`
+	Winner             string                   `json:"winner,omitempty"`
+	Inventory          *Inventory               `json:"inventory,omitempty"`
+	LastProcessedInput int                      `json:"lastProcessedInput,omitempty"`
+	Impacts            []BulletImpact           `json:"impacts,omitempty"`
+}
+
+// ZoneDiff is the whole zone geometry. It is sent as one piece whenever any
+// part of it changes, so fields that go back to zero are never lost.
+type ZoneDiff struct {
+	CenterX      float64 `json:"centerX"`
+	CenterY      float64 `json:"centerY"`
+	Radius       float64 `json:"radius"`
+	NextX        float64 `json:"nextX"`
+	NextY        float64 `json:"nextY"`
+	NextRadius   float64 `json:"nextRadius"`
+	Stage        int     `json:"stage"`
+	State        string  `json:"state"`
+	StateEndTick int     `json:"stateEndTick"`
+}
+
+type PhaseMessage struct {
+	Type          string `json:"type"`
+	Phase         string `json:"phase"`
+	PreviousPhase string `json:"previousPhase,omitempty"`
+	MatchID       int    `json:"matchId"`
+	Tick          int    `json:"tick"`
+	EndTick       int    `json:"endTick,omitempty"`
+	Countdown     int    `json:"countdown,omitempty"`
+	Winner        string `json:"winner,omitempty"`
+}
+
+type QueueMessage struct {
+	Type      string `json:"type"`
+	Position  int    `json:"position"`
+	Queued    int    `json:"queued"`
+	MatchSize int    `json:"matchSize"`
+	ETA       int    `json:"eta"`
 }
 
 type BotState struct {
-	TargetX       float64
-	TargetY       float64
-	LastDirChange int
-	MoveAngle     float64
+	Difficulty *BotDifficulty
+	Brain      BotBrain
 }
 
 type QueuedInput struct {
-	PlayerID string
-	MoveX    float64
-	MoveY    float64
-	Angle    float64
-	Tick     int
-	ClientX  float64
-	ClientY  float64
+	PlayerID   string
+	InputID    int
+	MoveX      float64
+	MoveY      float64
+	Angle      float64
+	Shoot      bool
+	Tick       int
+	ClientTick int
+	ClientX    float64
+	ClientY    float64
 }
 
 type GameServer struct {
+	roomID            string
+	rules             MatchRules
+	lobby             *Lobby
+	nextBotID         int
+	botInputs         []QueuedInput
+	participants      int
+	stop              chan struct{}
 	clients           map[*websocket.Conn]*clientConn
+	pendingJoins      int
 	gameState         *GameState
 	mu                sync.RWMutex
-	upgrader          websocket.Upgrader
 	nextBulletID      int
 	nextAmmoID        int
 	nextHealthID      int
+	nextArmorID       int
 	sessions          map[string]*Player
 	sessionMu         sync.RWMutex
 	botStates         map[string]*BotState
@@ -189,12 +379,25 @@ type GameServer struct {
 	chunkDataMu       sync.RWMutex
 	pendingChunks     []struct{ X, Y float64 }
 	inputQueue        map[string][]QueuedInput
+	shotQueue         map[string][]QueuedInput
 	inputQueueMu      sync.Mutex
+	lastInputID       map[string]int
+	processedInputID  map[string]int
+	inputRates        map[string]*InputRate
+	inputViolations   int
+	inputKicks        int
+	metrics           loopMetrics
 	currentTick       int
 	zoneDamageAccum   map[string]float64
 	zoneDamageAccumMu sync.Mutex
 	buildingGrid      *SpatialGrid
 	treeGrid          *SpatialGrid
+	pendingEvents     []interface{}
+	pendingImpacts    []BulletImpact
+	positionHistory   map[string]*PositionHistory
+	zoneFromX         float64
+	zoneFromY         float64
+	zoneFromRadius    float64
 }
 
 type InputMessage struct {
@@ -206,4 +409,11 @@ type InputMessage struct {
 	Time    float64 `json:"time,omitempty"`
 	ClientX float64 `json:"clientX,omitempty"`
 	ClientY float64 `json:"clientY,omitempty"`
+	Slot    int     `json:"slot,omitempty"`
+	Item    string  `json:"item,omitempty"`
+	Tick    int     `json:"tick,omitempty"`
+	InputID int     `json:"inputId,omitempty"`
+
+	PlayerID string      `json:"playerId,omitempty"`
+	Rules    *MatchRules `json:"rules,omitempty"`
 }
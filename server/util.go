@@ -23,24 +23,29 @@ func validateInput(moveX, moveY, angle float64) bool {
 	return true
 }
 
-func calculateZoneDamagePerTick(zoneRadius float64) float64 {
-	if zoneRadius >= 3000 {
-		return 0.1
-	} else if zoneRadius >= 2000 {
-		return 0.1 + (3000-zoneRadius)/1000.0*0.1
-	} else if zoneRadius >= 1000 {
-		return 0.2 + (2000-zoneRadius)/1000.0*0.1
-	} else if zoneRadius >= 500 {
-		return 0.3 + (1000-zoneRadius)/500.0*0.2
-	} else {
-		return 0.5
-	}
+// msToTicks converts a duration from config into whole simulation ticks,
+// rounding up so short cooldowns never become zero.
+func msToTicks(ms int64) int {
+	return int((ms*TICK_RATE + 999) / 1000)
 }
 
 func floatsEqual(a, b float64, epsilon float64) bool {
 	return math.Abs(a-b) < epsilon
 }
 
+func playerChanged(player, lastPlayer *Player) bool {
+	return lastPlayer == nil || !floatsEqual(player.X, lastPlayer.X, COORD_EPSILON) || !floatsEqual(player.Y, lastPlayer.Y, COORD_EPSILON) ||
+		!floatsEqual(player.Angle, lastPlayer.Angle, ANGLE_EPSILON) || player.Health != lastPlayer.Health ||
+		player.Ammo != lastPlayer.Ammo || player.Magazine != lastPlayer.Magazine || player.Weapon != lastPlayer.Weapon ||
+		player.Alive != lastPlayer.Alive || player.Score != lastPlayer.Score ||
+		player.Kills != lastPlayer.Kills || player.Reloading != lastPlayer.Reloading ||
+		!floatsEqual(player.ReloadProgress, lastPlayer.ReloadProgress, COORD_EPSILON) ||
+		player.VestTier != lastPlayer.VestTier || player.VestDurability != lastPlayer.VestDurability ||
+		player.HelmetTier != lastPlayer.HelmetTier || player.HelmetDurability != lastPlayer.HelmetDurability ||
+		player.Healing != lastPlayer.Healing || !floatsEqual(player.HealProgress, lastPlayer.HealProgress, COORD_EPSILON) ||
+		player.Boost != lastPlayer.Boost
+}
+
 func (gs *GameServer) findValidPosition(startX, startY, radius float64, maxAttempts int, zoneConstrained bool) (float64, float64, bool) {
 	for attempts := 0; attempts < maxAttempts; attempts++ {
 		x := startX
@@ -49,17 +54,12 @@ func (gs *GameServer) findValidPosition(startX, startY, radius float64, maxAttem
 		if zoneConstrained && attempts > 0 {
 			angle := rand.Float64() * 2 * math.Pi
 			dist := rand.Float64() * (gs.gameState.ZoneRadius - 50)
-			x = gs.gameState.ZoneCenter + math.Cos(angle)*dist
-			y = gs.gameState.ZoneCenter + math.Sin(angle)*dist
+			x = gs.gameState.ZoneCenterX + math.Cos(angle)*dist
+			y = gs.gameState.ZoneCenterY + math.Sin(angle)*dist
 		}
 
-		if zoneConstrained {
-			dx := x - gs.gameState.ZoneCenter
-			dy := y - gs.gameState.ZoneCenter
-			distFromZone := math.Sqrt(dx*dx + dy*dy)
-			if distFromZone > gs.gameState.ZoneRadius-20 {
-				continue
-			}
+		if zoneConstrained && !gs.isInsideZone(x, y, 20) {
+			continue
 		}
 
 		validPosition := true
@@ -1,192 +1,224 @@
 package main
 
 import (
+	_ "embed"
+	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
+	"os"
 )
 
+//go:embed weapons.json
+var defaultWeaponConfig []byte
+
 type Weapon interface {
 	GetName() string
-	GetCooldown() int64
+	// GetCooldown and GetReloadTime are in simulation ticks.
+	GetCooldown() int
 	GetInitialAmmo() int
+	GetDamage() int
+	GetMagazineSize() int
+	GetReloadTime() int
 	CreateBullets(player *Player, gs *GameServer) []*Bullet
 }
 
-type Pistol struct{}
-
-func (p *Pistol) GetName() string {
-	return "pistol"
+type WeaponDefinition struct {
+	Name          string  `json:"name"`
+	Damage        int     `json:"damage"`
+	BulletSpeed   float64 `json:"bulletSpeed"`
+	Range         float64 `json:"range"`
+	PelletCount   int     `json:"pelletCount"`
+	Spread        float64 `json:"spread"`
+	SpreadPattern string  `json:"spreadPattern"`
+	CooldownMs    int64   `json:"cooldownMs"`
+	MagazineSize  int     `json:"magazineSize"`
+	ReloadMs      int64   `json:"reloadMs"`
+	InitialAmmo   int     `json:"initialAmmo"`
+	Falloff       Falloff `json:"falloff"`
 }
 
-func (p *Pistol) GetCooldown() int64 {
-	return 500
+// Falloff scales damage linearly from full at Start to MinFactor at End.
+type Falloff struct {
+	Start     float64 `json:"start"`
+	End       float64 `json:"end"`
+	MinFactor float64 `json:"minFactor"`
 }
 
-func (p *Pistol) GetInitialAmmo() int {
-	return 30
+type WeaponConfig struct {
+	DefaultWeapon string             `json:"defaultWeapon"`
+	Weapons       []WeaponDefinition `json:"weapons"`
 }
 
-func (p *Pistol) CreateBullets(player *Player, gs *GameServer) []*Bullet {
-	bulletID := fmt.Sprintf("bullet_%d", gs.nextBulletID)
-	gs.nextBulletID++
-
-	bullet := &Bullet{
-		ID:       bulletID,
-		PlayerID: player.ID,
-		X:        roundFloat(player.X, 2),
-		Y:        roundFloat(player.Y, 2),
-		Angle:    roundFloat(player.Angle, 4),
-		Speed:    18.0,
-		Active:   true,
-		Weapon:   player.Weapon,
-	}
-
-	log.Printf("[BULLET CREATED] Player %s shot pistol bullet %s at (%.2f, %.2f) angle %.2f, ammo now: %d",
-		player.ID, bulletID, player.X, player.Y, player.Angle, player.Ammo)
-
-	return []*Bullet{bullet}
+type ConfigWeapon struct {
+	def WeaponDefinition
 }
 
-type Rifle struct{}
-
-func (r *Rifle) GetName() string {
-	return "rifle"
+func (w *ConfigWeapon) GetName() string {
+	return w.def.Name
 }
 
-func (r *Rifle) GetCooldown() int64 {
-	return 450
+func (w *ConfigWeapon) GetCooldown() int {
+	return msToTicks(w.def.CooldownMs)
 }
 
-func (r *Rifle) GetInitialAmmo() int {
-	return 20
+func (w *ConfigWeapon) GetInitialAmmo() int {
+	return w.def.InitialAmmo
 }
 
-func (r *Rifle) CreateBullets(player *Player, gs *GameServer) []*Bullet {
-	spreadAngle := 0.15
-
-	bulletID1 := fmt.Sprintf("bullet_%d", gs.nextBulletID)
-	gs.nextBulletID++
-	bullet1 := &Bullet{
-		ID:       bulletID1,
-		PlayerID: player.ID,
-		X:        roundFloat(player.X, 2),
-		Y:        roundFloat(player.Y, 2),
-		Angle:    roundFloat(player.Angle-spreadAngle, 4),
-		Speed:    18.0,
-		Active:   true,
-		Weapon:   player.Weapon,
-	}
-	log.Printf("[BULLET CREATED] Player %s shot rifle bullet %s at (%.2f, %.2f) angle %.2f",
-		player.ID, bulletID1, player.X, player.Y, player.Angle-spreadAngle)
-
-	bulletID2 := fmt.Sprintf("bullet_%d", gs.nextBulletID)
-	gs.nextBulletID++
-	bullet2 := &Bullet{
-		ID:       bulletID2,
-		PlayerID: player.ID,
-		X:        roundFloat(player.X, 2),
-		Y:        roundFloat(player.Y, 2),
-		Angle:    roundFloat(player.Angle+spreadAngle, 4),
-		Speed:    18.0,
-		Active:   true,
-		Weapon:   player.Weapon,
-	}
-	log.Printf("[BULLET CREATED] Player %s shot rifle bullet %s at (%.2f, %.2f) angle %.2f",
-		player.ID, bulletID2, player.X, player.Y, player.Angle+spreadAngle)
-
-	return []*Bullet{bullet1, bullet2}
+func (w *ConfigWeapon) GetDamage() int {
+	return w.def.Damage
 }
 
-type Machinegun struct{}
-
-func (m *Machinegun) GetName() string {
-	return "machinegun"
+func (w *ConfigWeapon) GetMagazineSize() int {
+	return w.def.MagazineSize
 }
 
-func (m *Machinegun) GetCooldown() int64 {
-	return 100
+func (w *ConfigWeapon) GetReloadTime() int {
+	return msToTicks(w.def.ReloadMs)
 }
 
-func (m *Machinegun) GetInitialAmmo() int {
-	return 50
+func (w *ConfigWeapon) spreadOffset(i int) float64 {
+	if w.def.Spread == 0 {
+		return 0
+	}
+	switch w.def.SpreadPattern {
+	case "random":
+		return (rand.Float64() - 0.5) * w.def.Spread
+	default:
+		if w.def.PelletCount <= 1 {
+			return 0
+		}
+		return -w.def.Spread/2 + w.def.Spread*float64(i)/float64(w.def.PelletCount-1)
+	}
 }
 
-func (m *Machinegun) CreateBullets(player *Player, gs *GameServer) []*Bullet {
-	bulletID := fmt.Sprintf("bullet_%d", gs.nextBulletID)
-	gs.nextBulletID++
-
-	bullet := &Bullet{
-		ID:       bulletID,
-		PlayerID: player.ID,
-		X:        roundFloat(player.X, 2),
-		Y:        roundFloat(player.Y, 2),
-		Angle:    roundFloat(player.Angle, 4),
-		Speed:    18.0,
-		Active:   true,
-		Weapon:   player.Weapon,
-	}
+func (w *ConfigWeapon) CreateBullets(player *Player, gs *GameServer) []*Bullet {
+	bullets := make([]*Bullet, 0, w.def.PelletCount)
 
-	log.Printf("[BULLET CREATED] Player %s shot machinegun bullet %s at (%.2f, %.2f) angle %.2f, ammo now: %d",
-		player.ID, bulletID, player.X, player.Y, player.Angle, player.Ammo)
+	for i := 0; i < w.def.PelletCount; i++ {
+		bulletID := fmt.Sprintf("bullet_%d", gs.nextBulletID)
+		gs.nextBulletID++
 
-	return []*Bullet{bullet}
-}
+		bullets = append(bullets, &Bullet{
+			ID:       bulletID,
+			PlayerID: player.ID,
+			X:        roundFloat(player.X, 2),
+			Y:        roundFloat(player.Y, 2),
+			Angle:    roundFloat(player.Angle+w.spreadOffset(i), 4),
+			Speed:    w.def.BulletSpeed,
+			Active:   true,
+			Weapon:   w.def.Name,
+			Damage:   w.def.Damage,
+			Range:    w.def.Range,
+			Falloff:  w.def.Falloff,
+		})
+	}
 
-type Shotgun struct{}
+	log.Printf("[BULLET CREATED] Player %s shot %s (%d bullets) at (%.2f, %.2f) angle %.2f, magazine now: %d",
+		player.ID, w.def.Name, len(bullets), player.X, player.Y, player.Angle, player.Magazine)
 
-func (s *Shotgun) GetName() string {
-	return "shotgun"
+	return bullets
 }
 
-func (s *Shotgun) GetCooldown() int64 {
-	return 800
-}
+var (
+	weaponInstances = map[string]Weapon{}
+	weaponNames     []string
+	defaultWeapon   string
+)
 
-func (s *Shotgun) GetInitialAmmo() int {
-	return 15
+func (d *WeaponDefinition) validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("weapon without name")
+	}
+	if d.PelletCount < 1 {
+		return fmt.Errorf("weapon %s: pelletCount must be at least 1", d.Name)
+	}
+	if d.BulletSpeed <= 0 {
+		return fmt.Errorf("weapon %s: bulletSpeed must be positive", d.Name)
+	}
+	if d.Range <= 0 {
+		return fmt.Errorf("weapon %s: range must be positive", d.Name)
+	}
+	if d.Falloff.End < d.Falloff.Start || d.Falloff.MinFactor < 0 || d.Falloff.MinFactor > 1 {
+		return fmt.Errorf("weapon %s: invalid falloff", d.Name)
+	}
+	if d.MagazineSize < 1 {
+		return fmt.Errorf("weapon %s: magazineSize must be at least 1", d.Name)
+	}
+	if d.Damage < 0 || d.CooldownMs < 0 || d.ReloadMs < 0 || d.InitialAmmo < 0 {
+		return fmt.Errorf("weapon %s: negative values are not allowed", d.Name)
+	}
+	if d.SpreadPattern != "" && d.SpreadPattern != "even" && d.SpreadPattern != "random" {
+		return fmt.Errorf("weapon %s: unknown spreadPattern %q", d.Name, d.SpreadPattern)
+	}
+	return nil
 }
 
-func (s *Shotgun) CreateBullets(player *Player, gs *GameServer) []*Bullet {
-	bullets := make([]*Bullet, 0)
-	pelletCount := 5
-	spreadAngle := 0.3
-
-	for i := 0; i < pelletCount; i++ {
-		bulletID := fmt.Sprintf("bullet_%d", gs.nextBulletID)
-		gs.nextBulletID++
+func parseWeaponConfig(data []byte) (*WeaponConfig, error) {
+	var config WeaponConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if len(config.Weapons) == 0 {
+		return nil, fmt.Errorf("no weapons defined")
+	}
 
-		angleOffset := -spreadAngle/2 + (spreadAngle * float64(i) / float64(pelletCount-1))
+	seen := make(map[string]bool)
+	for i := range config.Weapons {
+		if err := config.Weapons[i].validate(); err != nil {
+			return nil, err
+		}
+		if seen[config.Weapons[i].Name] {
+			return nil, fmt.Errorf("duplicate weapon %s", config.Weapons[i].Name)
+		}
+		seen[config.Weapons[i].Name] = true
+	}
 
-		bullet := &Bullet{
-			ID:       bulletID,
-			PlayerID: player.ID,
-			X:        roundFloat(player.X, 2),
-			Y:        roundFloat(player.Y, 2),
-			Angle:    roundFloat(player.Angle+angleOffset, 4),
-			Speed:    18.0,
-			Active:   true,
-			Weapon:   player.Weapon,
+	if config.DefaultWeapon == "" {
+		config.DefaultWeapon = config.Weapons[0].Name
+	}
+	if !seen[config.DefaultWeapon] {
+		return nil, fmt.Errorf("default weapon %s is not defined", config.DefaultWeapon)
+	}
+	return &config, nil
+}
+
+// LoadWeapons reads weapon definitions from path, or the embedded defaults
+// when path is empty.
+func LoadWeapons(path string) error {
+	data := defaultWeaponConfig
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return err
 		}
+	}
 
-		bullets = append(bullets, bullet)
+	config, err := parseWeaponConfig(data)
+	if err != nil {
+		return fmt.Errorf("invalid weapon config: %w", err)
 	}
 
-	log.Printf("[BULLET CREATED] Player %s shot shotgun %d pellets at (%.2f, %.2f), ammo now: %d",
-		player.ID, pelletCount, player.X, player.Y, player.Ammo)
+	instances := make(map[string]Weapon, len(config.Weapons))
+	names := make([]string, 0, len(config.Weapons))
+	for _, def := range config.Weapons {
+		instances[def.Name] = &ConfigWeapon{def: def}
+		names = append(names, def.Name)
+	}
 
-	return bullets
-}
+	weaponInstances = instances
+	weaponNames = names
+	defaultWeapon = config.DefaultWeapon
 
-var weaponInstances = map[string]Weapon{
-	"pistol":     &Pistol{},
-	"rifle":      &Rifle{},
-	"machinegun": &Machinegun{},
-	"shotgun":    &Shotgun{},
+	log.Printf("Loaded %d weapons: %v", len(names), names)
+	return nil
 }
 
 func GetWeapon(weaponType string) Weapon {
 	if weapon, ok := weaponInstances[weaponType]; ok {
 		return weapon
 	}
-	return weaponInstances["pistol"]
+	return weaponInstances[defaultWeapon]
 }
@@ -39,13 +39,8 @@ func (gs *GameServer) isValidTreePosition(x, y float64, size float64) bool {
 }
 
 func (gs *GameServer) isValidPickupPosition(x, y float64, radius float64, withinZone bool) bool {
-	if withinZone {
-		dx := x - gs.gameState.ZoneCenter
-		dy := y - gs.gameState.ZoneCenter
-		distFromZone := math.Sqrt(dx*dx + dy*dy)
-		if distFromZone > gs.gameState.ZoneRadius-radius {
-			return false
-		}
+	if withinZone && !gs.isInsideZone(x, y, radius) {
+		return false
 	}
 
 	for _, building := range gs.gameState.Buildings {
@@ -307,8 +302,8 @@ func (gs *GameServer) findValidPickupPosition(radius float64, withinZone bool) (
 			maxDist := gs.gameState.ZoneRadius - radius - 20
 			minDist := maxDist * 0.2
 			dist := minDist + math.Sqrt(rand.Float64())*(maxDist-minDist)
-			x = gs.gameState.ZoneCenter + math.Cos(angle)*dist
-			y = gs.gameState.ZoneCenter + math.Sin(angle)*dist
+			x = gs.gameState.ZoneCenterX + math.Cos(angle)*dist
+			y = gs.gameState.ZoneCenterY + math.Sin(angle)*dist
 		} else {
 			x = rand.Float64()*10000 - 5000
 			y = rand.Float64()*10000 - 5000
@@ -324,7 +319,7 @@ func (gs *GameServer) findValidPickupPosition(radius float64, withinZone bool) (
 		maxDist := gs.gameState.ZoneRadius - radius - 20
 		minDist := maxDist * 0.2
 		dist := minDist + math.Sqrt(rand.Float64())*(maxDist-minDist)
-		return gs.gameState.ZoneCenter + math.Cos(angle)*dist, gs.gameState.ZoneCenter + math.Sin(angle)*dist
+		return gs.gameState.ZoneCenterX + math.Cos(angle)*dist, gs.gameState.ZoneCenterY + math.Sin(angle)*dist
 	}
 	return rand.Float64()*10000 - 5000, rand.Float64()*10000 - 5000
 }
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Every client has one writer goroutine. Discrete messages (events, pongs)
+// go through a bounded queue; state snapshots are kept in a single slot so a
+// client that falls behind gets one diff against the newest state instead of
+// a backlog of stale ones.
+const (
+	CLIENT_SEND_QUEUE_SIZE       = 64
+	CLIENT_WRITE_TIMEOUT         = 5 * time.Second
+	CLIENT_MAX_BEHIND_BROADCASTS = 5 * BROADCAST_RATE
+	CLIENT_CLOSE_TIMEOUT         = time.Second
+)
+
+type outboundMessage struct {
+	messageType int
+	data        []byte
+}
+
+func newClientConn(conn *websocket.Conn, player *Player, caps Capabilities) *clientConn {
+	c := &clientConn{
+		conn:        conn,
+		player:      player,
+		knownChunks: make(map[string]bool),
+		caps:        caps,
+		send:        make(chan outboundMessage, CLIENT_SEND_QUEUE_SIZE),
+		stateReady:  make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	if caps.Encoding == ENCODING_BINARY {
+		c.encoder = newBinaryEncoder()
+	}
+	return c
+}
+
+// enqueue queues a pre-encoded message for the writer. A client whose queue
+// is full is evicted rather than allowed to hold up the broadcast.
+func (c *clientConn) enqueue(messageType int, data []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- outboundMessage{messageType: messageType, data: data}:
+		return true
+	default:
+		c.evict("send queue full")
+		return false
+	}
+}
+
+func (c *clientConn) enqueueJSON(v interface{}) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Println("Marshal error:", err)
+		return false
+	}
+	return c.enqueue(websocket.TextMessage, data)
+}
+
+// queueState hands the writer the latest snapshot. If the previous one was
+// never written it is replaced, keeping its impacts since those are only
+// reported once.
+func (c *clientConn) queueState(state *DynamicState) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+
+	c.pendingMu.Lock()
+	if c.pending != nil {
+		merged := *state
+		merged.Impacts = append(append([]BulletImpact{}, c.pending.Impacts...), state.Impacts...)
+		state = &merged
+		c.behind++
+	} else {
+		c.behind = 0
+	}
+	c.pending = state
+	behind := c.behind
+	c.pendingMu.Unlock()
+
+	if behind >= CLIENT_MAX_BEHIND_BROADCASTS {
+		c.evict("fell too far behind")
+		return
+	}
+
+	select {
+	case c.stateReady <- struct{}{}:
+	default:
+	}
+}
+
+func (c *clientConn) takeState() *DynamicState {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	state := c.pending
+	c.pending = nil
+	return state
+}
+
+func (c *clientConn) evict(reason string) {
+	log.Printf("[NET] Evicting player %s: %s", c.player.ID, reason)
+	c.close(websocket.CloseTryAgainLater, reason)
+}
+
+// close stops the writer and closes the connection. The close frame is sent
+// from its own goroutine so callers never wait on a slow socket.
+func (c *clientConn) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		go func() {
+			c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(CLIENT_CLOSE_TIMEOUT))
+			c.conn.Close()
+		}()
+	})
+}
+
+// write sends one frame. Only the client's writer goroutine may call it.
+func (c *clientConn) write(messageType int, data []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(CLIENT_WRITE_TIMEOUT))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// writeLoop is the only goroutine that writes to the client's socket. It
+// sends the init message first, then queued messages and state diffs until
+// the client is closed or a write fails.
+func (gs *GameServer) writeLoop(c *clientConn, sessionID string) {
+	if err := gs.sendInit(c, sessionID); err != nil {
+		log.Printf("Error sending initial state: %v", err)
+		c.close(websocket.CloseInternalServerErr, "")
+		return
+	}
+	log.Printf("Sent initial state to player %s", c.player.ID)
+
+	for {
+		var err error
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			err = c.write(msg.messageType, msg.data)
+		case <-c.stateReady:
+			err = gs.writeState(c)
+		}
+		if err != nil {
+			log.Printf("Write error for player %s: %v", c.player.ID, err)
+			c.close(websocket.CloseGoingAway, "")
+			return
+		}
+	}
+}
+
+func (gs *GameServer) sendInit(c *clientConn, sessionID string) error {
+	gs.mu.RLock()
+	x, y := c.player.X, c.player.Y
+	gs.mu.RUnlock()
+
+	if err := gs.sendChunksToClient(c, x, y); err != nil {
+		return err
+	}
+
+	initDiff := gs.createStateDiff(c)
+	initDiff.Type = "init"
+
+	initMsg := map[string]interface{}{
+		"type":         "init",
+		"playerId":     c.player.ID,
+		"sessionId":    sessionID,
+		"roomId":       gs.roomID,
+		"capabilities": c.caps,
+		"state":        initDiff,
+	}
+
+	data, err := json.Marshal(initMsg)
+	if err != nil {
+		return err
+	}
+	return c.write(websocket.TextMessage, data)
+}
+
+// writeState flushes queued messages so events precede the diff of the
+// broadcast they came with, then diffs the newest snapshot.
+func (gs *GameServer) writeState(c *clientConn) error {
+	for len(c.send) > 0 {
+		msg := <-c.send
+		if err := c.write(msg.messageType, msg.data); err != nil {
+			return err
+		}
+	}
+
+	state := c.takeState()
+	if state == nil {
+		return nil
+	}
+
+	gs.mu.RLock()
+	player := gs.gameState.Players[c.player.ID]
+	var x, y float64
+	if player != nil {
+		x, y = player.X, player.Y
+	}
+	gs.mu.RUnlock()
+	if player == nil {
+		return nil
+	}
+
+	if err := gs.sendChunksToClient(c, x, y); err != nil {
+		return err
+	}
+
+	diff := gs.createStateDiffFromState(c, state)
+	messageType, data, err := c.encodeStateDiff(diff)
+	if err != nil {
+		log.Println("Marshal error:", err)
+		return nil
+	}
+	return c.write(messageType, data)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log"
+	"math"
+	"math/rand"
+)
+
+type ZoneStage struct {
+	WaitTicks     int
+	ShrinkTicks   int
+	TargetRadius  float64
+	DamagePerTick float64
+}
+
+var zoneSchedule = []ZoneStage{
+	{WaitTicks: 60 * TICK_RATE, ShrinkTicks: 40 * TICK_RATE, TargetRadius: 2000, DamagePerTick: 0.1},
+	{WaitTicks: 45 * TICK_RATE, ShrinkTicks: 35 * TICK_RATE, TargetRadius: 1300, DamagePerTick: 0.2},
+	{WaitTicks: 40 * TICK_RATE, ShrinkTicks: 30 * TICK_RATE, TargetRadius: 800, DamagePerTick: 0.3},
+	{WaitTicks: 30 * TICK_RATE, ShrinkTicks: 25 * TICK_RATE, TargetRadius: 450, DamagePerTick: 0.5},
+	{WaitTicks: 25 * TICK_RATE, ShrinkTicks: 20 * TICK_RATE, TargetRadius: 200, DamagePerTick: 0.8},
+	{WaitTicks: 20 * TICK_RATE, ShrinkTicks: 15 * TICK_RATE, TargetRadius: 50, DamagePerTick: 1.2},
+}
+
+func (gs *GameServer) resetZone() {
+	gs.gameState.ZoneCenterX = 0
+	gs.gameState.ZoneCenterY = 0
+	gs.gameState.ZoneRadius = ZONE_INITIAL_SIZE
+	gs.gameState.NextZoneX = 0
+	gs.gameState.NextZoneY = 0
+	gs.gameState.NextZoneRadius = ZONE_INITIAL_SIZE
+	gs.gameState.ZoneStage = 0
+	gs.gameState.ZoneState = ZONE_STATE_IDLE
+	gs.gameState.ZoneStateEndTick = 0
+}
+
+func (gs *GameServer) startZone(tick int) {
+	gs.gameState.ZoneStage = 0
+	gs.beginZoneWait(tick)
+}
+
+func (gs *GameServer) beginZoneWait(tick int) {
+	stage := zoneSchedule[gs.gameState.ZoneStage]
+
+	// The next circle always lies fully inside the current one.
+	maxOffset := math.Max(0, gs.gameState.ZoneRadius-stage.TargetRadius)
+	angle := rand.Float64() * 2 * math.Pi
+	dist := math.Sqrt(rand.Float64()) * maxOffset
+	gs.gameState.NextZoneX = roundFloat(gs.gameState.ZoneCenterX+math.Cos(angle)*dist, 2)
+	gs.gameState.NextZoneY = roundFloat(gs.gameState.ZoneCenterY+math.Sin(angle)*dist, 2)
+	gs.gameState.NextZoneRadius = stage.TargetRadius
+
+	gs.gameState.ZoneState = ZONE_STATE_WAITING
+	gs.gameState.ZoneStateEndTick = tick + gs.zoneTicks(stage.WaitTicks)
+
+	log.Printf("[ZONE] Stage %d: next circle (%.2f, %.2f) r=%.0f, shrinking at tick %d",
+		gs.gameState.ZoneStage, gs.gameState.NextZoneX, gs.gameState.NextZoneY, stage.TargetRadius, gs.gameState.ZoneStateEndTick)
+}
+
+func (gs *GameServer) updateZone(tick int) {
+	if gs.gameState.ZoneState == ZONE_STATE_IDLE || gs.gameState.ZoneState == ZONE_STATE_FINAL {
+		return
+	}
+
+	stage := zoneSchedule[gs.gameState.ZoneStage]
+
+	switch gs.gameState.ZoneState {
+	case ZONE_STATE_WAITING:
+		if tick >= gs.gameState.ZoneStateEndTick {
+			gs.zoneFromX = gs.gameState.ZoneCenterX
+			gs.zoneFromY = gs.gameState.ZoneCenterY
+			gs.zoneFromRadius = gs.gameState.ZoneRadius
+			gs.gameState.ZoneState = ZONE_STATE_SHRINKING
+			gs.gameState.ZoneStateEndTick = tick + gs.zoneTicks(stage.ShrinkTicks)
+		}
+	case ZONE_STATE_SHRINKING:
+		remaining := gs.gameState.ZoneStateEndTick - tick
+		if remaining <= 0 {
+			gs.gameState.ZoneCenterX = gs.gameState.NextZoneX
+			gs.gameState.ZoneCenterY = gs.gameState.NextZoneY
+			gs.gameState.ZoneRadius = gs.gameState.NextZoneRadius
+			if gs.gameState.ZoneStage+1 >= len(zoneSchedule) {
+				// The match is called once the final circle has held for
+				// FINAL_ZONE_TICKS.
+				gs.gameState.ZoneState = ZONE_STATE_FINAL
+				gs.gameState.ZoneStateEndTick = tick + FINAL_ZONE_TICKS
+				return
+			}
+			gs.gameState.ZoneStage++
+			gs.beginZoneWait(tick)
+			return
+		}
+
+		t := 1.0 - float64(remaining)/float64(gs.zoneTicks(stage.ShrinkTicks))
+		gs.gameState.ZoneCenterX = roundFloat(gs.zoneFromX+(gs.gameState.NextZoneX-gs.zoneFromX)*t, 2)
+		gs.gameState.ZoneCenterY = roundFloat(gs.zoneFromY+(gs.gameState.NextZoneY-gs.zoneFromY)*t, 2)
+		gs.gameState.ZoneRadius = roundFloat(gs.zoneFromRadius+(gs.gameState.NextZoneRadius-gs.zoneFromRadius)*t, 2)
+	}
+}
+
+// zoneTicks scales a stage duration by the room's zone speed.
+func (gs *GameServer) zoneTicks(ticks int) int {
+	return int(math.Ceil(float64(ticks) / gs.rules.ZoneSpeed))
+}
+
+func (gs *GameServer) zoneDamagePerTick() float64 {
+	if gs.gameState.ZoneState == ZONE_STATE_IDLE {
+		return 0
+	}
+	return zoneSchedule[gs.gameState.ZoneStage].DamagePerTick
+}
+
+func (gs *GameServer) isInsideZone(x, y, margin float64) bool {
+	dx := x - gs.gameState.ZoneCenterX
+	dy := y - gs.gameState.ZoneCenterY
+	return math.Sqrt(dx*dx+dy*dy) <= gs.gameState.ZoneRadius-margin
+}
+
+func (s *DynamicState) zone() *ZoneDiff {
+	return &ZoneDiff{
+		CenterX:      s.ZoneCenterX,
+		CenterY:      s.ZoneCenterY,
+		Radius:       s.ZoneRadius,
+		NextX:        s.NextZoneX,
+		NextY:        s.NextZoneY,
+		NextRadius:   s.NextZoneRadius,
+		Stage:        s.ZoneStage,
+		State:        s.ZoneState,
+		StateEndTick: s.ZoneStateEndTick,
+	}
+}
+
+func zoneChanged(current, last *DynamicState) bool {
+	return !floatsEqual(current.ZoneCenterX, last.ZoneCenterX, COORD_EPSILON) ||
+		!floatsEqual(current.ZoneCenterY, last.ZoneCenterY, COORD_EPSILON) ||
+		!floatsEqual(current.ZoneRadius, last.ZoneRadius, COORD_EPSILON) ||
+		!floatsEqual(current.NextZoneX, last.NextZoneX, COORD_EPSILON) ||
+		!floatsEqual(current.NextZoneY, last.NextZoneY, COORD_EPSILON) ||
+		!floatsEqual(current.NextZoneRadius, last.NextZoneRadius, COORD_EPSILON) ||
+		current.ZoneStage != last.ZoneStage || current.ZoneState != last.ZoneState ||
+		current.ZoneStateEndTick != last.ZoneStateEndTick
+}
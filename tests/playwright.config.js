@@ -23,7 +23,7 @@ export default defineConfig({
     ],
 
     webServer: {
-        command: 'cd .. && PORT=12344 make run',
+        command: 'cd .. && PORT=12344 MATCHMAKING_TIMEOUT=0s make run',
         url: 'http://localhost:12344',
         reuseExistingServer: true,
         timeout: 10000,